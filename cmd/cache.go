@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/HurSungYun/buf-kcat/internal/decoder"
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the descriptor cache",
+	Long: `Manage the cache of descriptors built from buf.yaml modules and .proto files.
+
+Descriptors are cached under $XDG_CACHE_HOME/buf-kcat, keyed by a hash of the
+module's .proto files and buf configuration, so repeated runs skip the build.
+Use --no-cache on any command to bypass the cache.`,
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove all cached descriptors",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, err := decoder.DefaultCacheDir()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		removed, err := decoder.ClearCache(dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Removed %d cached descriptor sets from %s\n", removed, dir)
+	},
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheClearCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
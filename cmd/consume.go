@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
+	"github.com/HurSungYun/buf-kcat/internal/decoder"
 	"github.com/HurSungYun/buf-kcat/internal/kafka"
 	"github.com/spf13/cobra"
 )
@@ -23,6 +25,41 @@ Examples:
   # Consume using protobuf descriptor set
   buf-kcat consume -b localhost:9092 -t my-topic -p schema.desc -m mypackage.MyMessage
   
+  # Watch several topics at once, or every topic matching a regex
+  buf-kcat consume -b localhost:9092 -t orders,payments -p buf.yaml -m mypackage.MyMessage
+  buf-kcat consume -b localhost:9092 --topic-regex 'events\..*' -p buf.yaml -m mypackage.MyMessage
+  
+  # Decode each topic with its own message type
+  buf-kcat consume -b localhost:9092 -t orders.v1,users -p buf.yaml --type-map orders.v1=orders.OrderEvent,users=events.UserEvent
+  
+  # Identify what is on an unknown topic
+  buf-kcat consume -b localhost:9092 -t mystery-topic -p buf.yaml -m auto -c 5 -v
+  
+  # Only show records for some keys
+  buf-kcat consume -b localhost:9092 -t orders -p buf.yaml -m orders.Order --key-prefix tenant-42/ --key-regex '/order-[0-9]+$'
+  buf-kcat consume -b localhost:9092 -t orders -p buf.yaml -m orders.Order --key-file keys.txt -o beginning
+  
+  # Only show messages whose fields match a CEL expression
+  buf-kcat consume -b localhost:9092 -t orders -p buf.yaml -m orders.Order --filter 'customer_id == "42" && total > 100.0'
+  
+  # Decode the keys of a compacted topic as protobuf messages
+  buf-kcat consume -b localhost:9092 -t orders-compacted -p buf.yaml -m orders.Order --key-type orders.OrderKey
+  
+  # Decode a protobuf-encoded header along with the value
+  buf-kcat consume -b localhost:9092 -t my-topic -p buf.yaml -m mypackage.MyMessage --header-type trace=mypackage.TraceContext
+  
+  # Look at the wire format of a topic without any descriptors
+  buf-kcat consume -b localhost:9092 -t my-topic --decode-raw -f protoscope
+  
+  # Decode Confluent-serialized topics using the schema registry
+  buf-kcat consume -b localhost:9092 -t my-topic --schema-registry http://localhost:8081
+  
+  # Match the JSON expected by a downstream service
+  buf-kcat consume -b localhost:9092 -t my-topic -p buf.yaml -m mypackage.MyMessage --json-names --emit-defaults --int64-as-numbers
+  
+  # Inspect a single record at a known partition and offset
+  buf-kcat consume -b localhost:9092 -t my-topic -p buf.yaml -m mypackage.MyMessage -o 3:12345 -c 1
+  
   # Or use without 'consume' (default command)
   buf-kcat -b localhost:9092 -t my-topic -p buf.yaml -m mypackage.MyMessage`,
 	Run: runConsume,
@@ -31,34 +68,71 @@ Examples:
 func init() {
 	// Consumer-specific flags
 	consumerCmd.Flags().StringSliceVarP(&brokers, "brokers", "b", []string{"localhost:9092"}, "Kafka brokers (comma-separated)")
-	consumerCmd.Flags().StringVarP(&topic, "topic", "t", "", "Kafka topic (required)")
-	consumerCmd.Flags().StringVarP(&group, "group", "g", "buf-kcat", "Consumer group")
-	consumerCmd.Flags().StringVarP(&messageType, "message-type", "m", "", "Protobuf message type (required)")
-	consumerCmd.Flags().StringVarP(&outputFormat, "format", "f", "json", "Output format: json, json-compact, table, raw, pretty")
-	consumerCmd.Flags().StringVarP(&offset, "offset", "o", "end", "Start offset: beginning, end, stored, or timestamp:UNIX_MS")
+	consumerCmd.Flags().StringSliceVarP(&topics, "topic", "t", nil, "Kafka topics (comma-separated; required unless --topic-regex is set)")
+	consumerCmd.Flags().StringVar(&topicRegex, "topic-regex", "", "Consume all topics matching this regular expression")
+	consumerCmd.Flags().StringVarP(&group, "group", "g", "", "Consumer group to join (default: consume all partitions without a group)")
+	consumerCmd.Flags().StringVarP(&messageType, "message-type", "m", "", "Protobuf message type, or 'auto' to infer it (required unless every topic is in the type map)")
+	consumerCmd.Flags().StringToStringVar(&typeMap, "type-map", nil, "Per-topic message types as topic=pkg.Type,... (topics may be patterns like events.*)")
+	consumerCmd.Flags().StringVar(&typeMapFile, "type-map-file", "", "File of topic=pkg.Type lines mapping topics to message types")
+	consumerCmd.Flags().StringVar(&typeHeader, "type-header", "", "Record header naming each message's type (e.g. proto-type or content-type), falling back to -m")
+	consumerCmd.Flags().StringToStringVar(&headerTypes, "header-type", nil, "Decode record headers as message types, as name=pkg.Type,...")
+	consumerCmd.Flags().StringVarP(&outputFormat, "format", "f", "json", "Output format: json, json-compact, table, raw, pretty, protoscope")
+	consumerCmd.Flags().BoolVar(&emitDefaults, "emit-defaults", false, "Render unset fields with their default values")
+	consumerCmd.Flags().BoolVar(&jsonNames, "json-names", false, "Render field names in lowerCamelCase instead of as declared in the .proto file")
+	consumerCmd.Flags().BoolVar(&enumsAsInts, "enums-as-ints", false, "Render enum values as numbers instead of names")
+	consumerCmd.Flags().BoolVar(&int64AsNumbers, "int64-as-numbers", false, "Render 64-bit integers as JSON numbers instead of strings")
+	consumerCmd.Flags().BoolVar(&showUnknown, "show-unknown", false, "Add fields missing from the message type to the output as an _unknown object")
+	consumerCmd.Flags().BoolVar(&decodeRaw, "decode-raw", false, "Decode the wire format without descriptors, like protoc --decode_raw")
+	consumerCmd.Flags().StringVar(&registryURL, "schema-registry", "", "Confluent Schema Registry URL for decoding Confluent-framed payloads (replaces -p)")
+	consumerCmd.Flags().Int32SliceVarP(&partitions, "partition", "P", nil, "Consume only these partitions, without joining a consumer group")
+	consumerCmd.Flags().StringVarP(&offset, "offset", "o", "end", "Start offset: beginning, end, stored, an absolute offset, timestamp:<unix-ms|RFC3339|-15m>, or per partition as 3:12345,5:900")
+	consumerCmd.Flags().StringVar(&until, "until", "", "Stop each partition at: now (high watermark at startup), offset:N, or timestamp:<unix-ms|RFC3339|-15m>")
 	consumerCmd.Flags().IntVarP(&count, "count", "c", 0, "Number of messages to consume (0 = unlimited)")
 	consumerCmd.Flags().BoolVar(&follow, "follow", false, "Continue consuming messages (like tail -f)")
-	consumerCmd.Flags().StringVarP(&keyFilter, "key", "k", "", "Filter by message key (exact match)")
-	consumerCmd.Flags().StringVarP(&protoDir, "proto", "p", "buf.yaml", "Path to buf.yaml file or protobuf descriptor set (.desc/.pb/.protoset)")
+	consumerCmd.Flags().StringVarP(&keyFilter, "key", "k", "", "Filter by message key (exact match; hex:... or base64:... for binary keys)")
+	consumerCmd.Flags().StringVar(&keyFile, "key-file", "", "Filter by the keys listed in this file, one per line")
+	consumerCmd.Flags().StringVar(&keyPrefix, "key-prefix", "", "Filter by key prefix (hex:... or base64:... for binary prefixes)")
+	consumerCmd.Flags().StringVar(&keyRegex, "key-regex", "", "Filter by keys matching this regular expression")
+	consumerCmd.Flags().StringVar(&keyFormat, "key-format", "string", "Key format: string, hex, base64, or int64-big-endian")
+	consumerCmd.Flags().StringVar(&keyType, "key-type", "", "Decode keys as this protobuf message type")
+	consumerCmd.Flags().StringVar(&filterExpr, "filter", "", "Only show messages for which this CEL expression over their fields is true")
+	consumerCmd.Flags().StringVar(&commitMode, "commit", "none", "Commit offsets for --group: none, after-print, or periodic")
+	consumerCmd.Flags().DurationVar(&commitInterval, "commit-interval", 5*time.Second, "Interval between commits with --commit periodic")
+	consumerCmd.Flags().StringVarP(&protoDir, "proto", "p", "buf.yaml", "Path to buf.yaml, a .proto file or directory, a protobuf descriptor set (.desc/.pb/.protoset), or a BSR module (buf.build/owner/repo[:ref])")
 	consumerCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Verbose output")
 
-	_ = consumerCmd.MarkFlagRequired("topic")
-	_ = consumerCmd.MarkFlagRequired("message-type")
-
 	// Also add the same flags to root for backward compatibility
 	rootCmd.Flags().StringSliceVarP(&brokers, "brokers", "b", []string{"localhost:9092"}, "Kafka brokers (comma-separated)")
-	rootCmd.Flags().StringVarP(&topic, "topic", "t", "", "Kafka topic (required)")
-	rootCmd.Flags().StringVarP(&group, "group", "g", "buf-kcat", "Consumer group")
-	rootCmd.Flags().StringVarP(&messageType, "message-type", "m", "", "Protobuf message type (required)")
-	rootCmd.Flags().StringVarP(&outputFormat, "format", "f", "json", "Output format: json, json-compact, table, raw, pretty")
-	rootCmd.Flags().StringVarP(&offset, "offset", "o", "end", "Start offset: beginning, end, stored, or timestamp:UNIX_MS")
+	rootCmd.Flags().StringSliceVarP(&topics, "topic", "t", nil, "Kafka topics (comma-separated; required unless --topic-regex is set)")
+	rootCmd.Flags().StringVar(&topicRegex, "topic-regex", "", "Consume all topics matching this regular expression")
+	rootCmd.Flags().StringVarP(&group, "group", "g", "", "Consumer group to join (default: consume all partitions without a group)")
+	rootCmd.Flags().StringVarP(&messageType, "message-type", "m", "", "Protobuf message type, or 'auto' to infer it (required unless every topic is in the type map)")
+	rootCmd.Flags().StringToStringVar(&typeMap, "type-map", nil, "Per-topic message types as topic=pkg.Type,... (topics may be patterns like events.*)")
+	rootCmd.Flags().StringVar(&typeMapFile, "type-map-file", "", "File of topic=pkg.Type lines mapping topics to message types")
+	rootCmd.Flags().StringVar(&typeHeader, "type-header", "", "Record header naming each message's type (e.g. proto-type or content-type), falling back to -m")
+	rootCmd.Flags().StringToStringVar(&headerTypes, "header-type", nil, "Decode record headers as message types, as name=pkg.Type,...")
+	rootCmd.Flags().StringVarP(&outputFormat, "format", "f", "json", "Output format: json, json-compact, table, raw, pretty, protoscope")
+	rootCmd.Flags().BoolVar(&emitDefaults, "emit-defaults", false, "Render unset fields with their default values")
+	rootCmd.Flags().BoolVar(&jsonNames, "json-names", false, "Render field names in lowerCamelCase instead of as declared in the .proto file")
+	rootCmd.Flags().BoolVar(&enumsAsInts, "enums-as-ints", false, "Render enum values as numbers instead of names")
+	rootCmd.Flags().BoolVar(&int64AsNumbers, "int64-as-numbers", false, "Render 64-bit integers as JSON numbers instead of strings")
+	rootCmd.Flags().BoolVar(&showUnknown, "show-unknown", false, "Add fields missing from the message type to the output as an _unknown object")
+	rootCmd.Flags().BoolVar(&decodeRaw, "decode-raw", false, "Decode the wire format without descriptors, like protoc --decode_raw")
+	rootCmd.Flags().StringVar(&registryURL, "schema-registry", "", "Confluent Schema Registry URL for decoding Confluent-framed payloads (replaces -p)")
+	rootCmd.Flags().Int32SliceVarP(&partitions, "partition", "P", nil, "Consume only these partitions, without joining a consumer group")
+	rootCmd.Flags().StringVarP(&offset, "offset", "o", "end", "Start offset: beginning, end, stored, an absolute offset, timestamp:<unix-ms|RFC3339|-15m>, or per partition as 3:12345,5:900")
+	rootCmd.Flags().StringVar(&until, "until", "", "Stop each partition at: now (high watermark at startup), offset:N, or timestamp:<unix-ms|RFC3339|-15m>")
 	rootCmd.Flags().IntVarP(&count, "count", "c", 0, "Number of messages to consume (0 = unlimited)")
 	rootCmd.Flags().BoolVar(&follow, "follow", false, "Continue consuming messages (like tail -f)")
-	rootCmd.Flags().StringVarP(&keyFilter, "key", "k", "", "Filter by message key (exact match)")
-
-	// Mark required flags for root command as well
-	_ = rootCmd.MarkFlagRequired("topic")
-	_ = rootCmd.MarkFlagRequired("message-type")
+	rootCmd.Flags().StringVarP(&keyFilter, "key", "k", "", "Filter by message key (exact match; hex:... or base64:... for binary keys)")
+	rootCmd.Flags().StringVar(&keyFile, "key-file", "", "Filter by the keys listed in this file, one per line")
+	rootCmd.Flags().StringVar(&keyPrefix, "key-prefix", "", "Filter by key prefix (hex:... or base64:... for binary prefixes)")
+	rootCmd.Flags().StringVar(&keyRegex, "key-regex", "", "Filter by keys matching this regular expression")
+	rootCmd.Flags().StringVar(&keyFormat, "key-format", "string", "Key format: string, hex, base64, or int64-big-endian")
+	rootCmd.Flags().StringVar(&keyType, "key-type", "", "Decode keys as this protobuf message type")
+	rootCmd.Flags().StringVar(&filterExpr, "filter", "", "Only show messages for which this CEL expression over their fields is true")
+	rootCmd.Flags().StringVar(&commitMode, "commit", "none", "Commit offsets for --group: none, after-print, or periodic")
+	rootCmd.Flags().DurationVar(&commitInterval, "commit-interval", 5*time.Second, "Interval between commits with --commit periodic")
 
 	// Set default command behavior
 	rootCmd.Run = func(cmd *cobra.Command, args []string) {
@@ -71,29 +145,64 @@ func init() {
 
 func runConsume(cmd *cobra.Command, args []string) {
 	// Validate required flags
-	if topic == "" {
-		fmt.Fprintf(os.Stderr, "Error: topic is required\n")
+	if len(topics) == 0 && topicRegex == "" {
+		fmt.Fprintf(os.Stderr, "Error: required flag(s) \"topic\" or \"topic-regex\" not set\n")
 		fmt.Fprintf(os.Stderr, "Usage: buf-kcat -t <topic> -m <message-type> [options]\n")
 		os.Exit(1)
 	}
-	if messageType == "" {
-		fmt.Fprintf(os.Stderr, "Error: message-type is required\n")
+	if messageType == "" && len(typeMap) == 0 && typeMapFile == "" && typeHeader == "" && !decodeRaw && registryURL == "" {
+		fmt.Fprintf(os.Stderr, "Error: required flag(s) \"message-type\" not set\n")
 		fmt.Fprintf(os.Stderr, "Usage: buf-kcat -t <topic> -m <message-type> [options]\n")
 		os.Exit(1)
 	}
 
+	// Schemas come from the registry unless descriptors are given explicitly.
+	protoPath := protoDir
+	if registryURL != "" && !cmd.Flags().Changed("proto") {
+		protoPath = ""
+	}
+
 	consumer, err := kafka.NewConsumer(kafka.ConsumerConfig{
 		Brokers:      brokers,
 		Group:        group,
-		Topic:        topic,
-		ProtoPath:    protoDir,
+		Topics:       topics,
+		TopicRegex:   topicRegex,
+		Partitions:   partitions,
+		ProtoPath:    protoPath,
+		ImportPaths:  importPaths,
+		CacheDir:     descriptorCacheDir(),
+		Strict:       strict,
 		MessageType:  messageType,
+		TypeMap:      typeMap,
+		TypeMapFile:  typeMapFile,
+		TypeHeader:   typeHeader,
+		HeaderTypes:  headerTypes,
+		DecodeRaw:    decodeRaw,
 		OutputFormat: outputFormat,
 		Offset:       offset,
+		Until:        until,
 		Count:        count,
 		Follow:       follow,
 		KeyFilter:    keyFilter,
+		KeyFile:      keyFile,
+		KeyPrefix:    keyPrefix,
+		KeyRegex:     keyRegex,
+		KeyFormat:    keyFormat,
+		KeyType:      keyType,
+		Filter:       filterExpr,
 		Verbose:      verbose,
+		JSON: decoder.JSONOptions{
+			EmitDefaults:   emitDefaults,
+			JSONNames:      jsonNames,
+			EnumsAsInts:    enumsAsInts,
+			Int64AsNumbers: int64AsNumbers,
+			ShowUnknown:    showUnknown,
+		},
+
+		SchemaRegistry: registryURL,
+
+		Commit:         commitMode,
+		CommitInterval: commitInterval,
 	})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
@@ -9,17 +9,23 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var listDiagnostics bool
+
 var listCmd = &cobra.Command{
 	Use:   "list [proto-directory]",
 	Short: "List available message types",
-	Long: `List all protobuf message types found in the proto directory.
+	Long: `List all protobuf message types found in the proto directory, followed
+by the enums, services and extensions they declare.
 	
 Examples:
   # List using flag
   buf-kcat list -p /path/to/protos
   
   # List using positional argument
-  buf-kcat list /path/to/protos`,
+  buf-kcat list /path/to/protos
+
+  # Show files that failed to load and why
+  buf-kcat list -p schema.desc --diagnostics`,
 	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		// Get proto directory from positional arg or flag
@@ -31,7 +37,11 @@ Examples:
 				protoDir = "."
 			}
 		}
-		dec, err := decoder.NewDecoder(protoDir, "")
+		dec, err := decoder.NewDecoderWithOptions(protoDir, "", decoder.Options{
+			ImportPaths: importPaths,
+			CacheDir:    descriptorCacheDir(),
+			Strict:      strict,
+		})
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to load protos: %v\n", err)
 			os.Exit(1)
@@ -44,5 +54,54 @@ Examples:
 		for _, t := range types {
 			fmt.Printf("  %s\n", t)
 		}
+
+		printNames("enums", dec.EnumTypes())
+		printNames("services", dec.Services())
+
+		extensions := dec.Extensions()
+		if len(extensions) > 0 {
+			extendees := make([]string, 0, len(extensions))
+			for extendee := range extensions {
+				extendees = append(extendees, extendee)
+			}
+			sort.Strings(extendees)
+
+			fmt.Printf("\nExtensions:\n")
+			for _, extendee := range extendees {
+				names := extensions[extendee]
+				sort.Strings(names)
+				fmt.Printf("  %s\n", extendee)
+				for _, name := range names {
+					fmt.Printf("    %s\n", name)
+				}
+			}
+		}
+
+		diagnostics := dec.Diagnostics()
+		if listDiagnostics {
+			fmt.Printf("\nLoaded %d files, %d failed\n", dec.FileCount(), len(diagnostics))
+			for _, diag := range diagnostics {
+				fmt.Printf("  %s\n", diag)
+			}
+		} else if len(diagnostics) > 0 {
+			fmt.Fprintf(os.Stderr, "\nWarning: %d files failed to load; run with --diagnostics for details\n", len(diagnostics))
+		}
 	},
 }
+
+// printNames prints a sorted section of the list output, omitting it when
+// there is nothing to show.
+func printNames(kind string, names []string) {
+	if len(names) == 0 {
+		return
+	}
+	sort.Strings(names)
+	fmt.Printf("\nFound %d %s:\n", len(names), kind)
+	for _, name := range names {
+		fmt.Printf("  %s\n", name)
+	}
+}
+
+func init() {
+	listCmd.Flags().BoolVar(&listDiagnostics, "diagnostics", false, "Report files that failed to load and why")
+}
@@ -12,10 +12,17 @@ import (
 )
 
 var (
+	produceTopic     string
 	produceKey       string
 	producePartition int32
 	produceFromFile  string
 	produceFormat    string
+	produceKeyFormat string
+	produceKeyType   string
+
+	produceWireFormat string
+	produceSchemaID   uint32
+	produceSubject    string
 )
 
 var produceCmd = &cobra.Command{
@@ -39,6 +46,15 @@ Examples:
   # Produce with specific key
   echo '{"order_id": "456"}' | buf-kcat produce -b localhost:9092 -t orders -p buf.yaml -m orders.Order -k "order-456"
 
+  # Produce with a protobuf-encoded key
+  echo '{"order_id": "456"}' | buf-kcat produce -b localhost:9092 -t orders -p buf.yaml -m orders.Order --key-type orders.OrderKey -k '{"order_id": "456"}'
+
+  # Produce in the Confluent Schema Registry wire format with a known schema ID
+  echo '{"user_id": "123"}' | buf-kcat produce -b localhost:9092 -t events -p buf.yaml -m events.UserEvent --wire-format confluent --schema-id 42
+
+  # Look up the schema ID of subject events-value in the registry
+  echo '{"user_id": "123"}' | buf-kcat produce -b localhost:9092 -t events -p buf.yaml -m events.UserEvent --wire-format confluent --schema-registry http://localhost:8081
+
   # Interactive mode - type JSON messages, one per line
   buf-kcat produce -b localhost:9092 -t events -p buf.yaml -m events.UserEvent`,
 	Run: runProduce,
@@ -46,13 +62,19 @@ Examples:
 
 func init() {
 	produceCmd.Flags().StringSliceVarP(&brokers, "brokers", "b", []string{"localhost:9092"}, "Kafka brokers (comma-separated)")
-	produceCmd.Flags().StringVarP(&topic, "topic", "t", "", "Kafka topic (required)")
-	produceCmd.Flags().StringVarP(&protoDir, "proto", "p", "buf.yaml", "Path to buf.yaml file or protobuf descriptor set (.desc/.pb/.protoset)")
+	produceCmd.Flags().StringVarP(&produceTopic, "topic", "t", "", "Kafka topic (required)")
+	produceCmd.Flags().StringVarP(&protoDir, "proto", "p", "buf.yaml", "Path to buf.yaml, a .proto file or directory, a protobuf descriptor set (.desc/.pb/.protoset), or a BSR module (buf.build/owner/repo[:ref])")
 	produceCmd.Flags().StringVarP(&messageType, "message-type", "m", "", "Protobuf message type (required)")
 	produceCmd.Flags().StringVarP(&produceKey, "key", "k", "", "Message key")
+	produceCmd.Flags().StringVar(&produceKeyFormat, "key-format", "string", "Format of --key: string, hex, base64, or int64-big-endian")
+	produceCmd.Flags().StringVar(&produceKeyType, "key-type", "", "Protobuf message type of the key, given to --key as JSON")
 	produceCmd.Flags().Int32VarP(&producePartition, "partition", "P", -1, "Specific partition to produce to (-1 for auto)")
 	produceCmd.Flags().StringVarP(&produceFromFile, "file", "F", "", "Read messages from file instead of stdin")
 	produceCmd.Flags().StringVarP(&produceFormat, "format", "f", "json", "Input format: json, json-compact")
+	produceCmd.Flags().StringVar(&produceWireFormat, "wire-format", "bare", "Value wire format: bare or confluent (Schema Registry framing)")
+	produceCmd.Flags().Uint32Var(&produceSchemaID, "schema-id", 0, "Schema ID for --wire-format confluent")
+	produceCmd.Flags().StringVar(&registryURL, "schema-registry", "", "Confluent Schema Registry URL to look up the schema ID and message indexes")
+	produceCmd.Flags().StringVar(&produceSubject, "subject", "", "Schema Registry subject to look up (default <topic>-value)")
 	produceCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Verbose output")
 
 	_ = produceCmd.MarkFlagRequired("topic")
@@ -63,7 +85,7 @@ func init() {
 
 func runProduce(cmd *cobra.Command, args []string) {
 	// Validate required flags
-	if topic == "" {
+	if produceTopic == "" {
 		fmt.Fprintf(os.Stderr, "Error: topic is required\n")
 		fmt.Fprintf(os.Stderr, "Usage: buf-kcat produce -t <topic> -m <message-type> [options]\n")
 		os.Exit(1)
@@ -77,12 +99,22 @@ func runProduce(cmd *cobra.Command, args []string) {
 	// Initialize producer
 	producer, err := kafka.NewProducer(kafka.ProducerConfig{
 		Brokers:     brokers,
-		Topic:       topic,
+		Topic:       produceTopic,
 		ProtoPath:   protoDir,
+		ImportPaths: importPaths,
+		CacheDir:    descriptorCacheDir(),
+		Strict:      strict,
 		MessageType: messageType,
 		Key:         produceKey,
+		KeyFormat:   produceKeyFormat,
+		KeyType:     produceKeyType,
 		Partition:   producePartition,
 		Verbose:     verbose,
+
+		WireFormat:     produceWireFormat,
+		SchemaID:       produceSchemaID,
+		SchemaRegistry: registryURL,
+		Subject:        produceSubject,
 	})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
@@ -92,7 +124,7 @@ func runProduce(cmd *cobra.Command, args []string) {
 
 	// Print connection status
 	fmt.Fprintf(os.Stderr, "Connected to Kafka brokers: %v\n", brokers)
-	fmt.Fprintf(os.Stderr, "Producing to topic '%s'\n", topic)
+	fmt.Fprintf(os.Stderr, "Producing to topic '%s'\n", produceTopic)
 	fmt.Fprintf(os.Stderr, "Message type: %s\n", messageType)
 	if produceKey != "" {
 		fmt.Fprintf(os.Stderr, "Using key: %s\n", produceKey)
@@ -100,6 +132,9 @@ func runProduce(cmd *cobra.Command, args []string) {
 	if producePartition >= 0 {
 		fmt.Fprintf(os.Stderr, "Producing to partition: %d\n", producePartition)
 	}
+	if id := producer.SchemaID(); id != 0 {
+		fmt.Fprintf(os.Stderr, "Wire format: confluent (schema ID %d)\n", id)
+	}
 
 	// Determine input source
 	var input *os.File
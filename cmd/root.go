@@ -3,22 +3,51 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"time"
 
+	"github.com/HurSungYun/buf-kcat/internal/decoder"
 	"github.com/spf13/cobra"
 )
 
 var (
 	brokers      []string
-	topic        string
+	topics       []string
+	topicRegex   string
 	group        string
 	protoDir     string
+	importPaths  []string
+	noCache      bool
+	strict       bool
 	messageType  string
+	typeMap      map[string]string
+	typeMapFile  string
+	typeHeader   string
+	headerTypes  map[string]string
+	decodeRaw    bool
+	registryURL  string
 	outputFormat string
+	partitions   []int32
 	offset       string
+	until        string
 	count        int
 	follow       bool
 	verbose      bool
 	keyFilter    string
+	keyFile      string
+	keyPrefix    string
+	keyRegex     string
+	keyFormat    string
+	keyType      string
+	filterExpr   string
+
+	emitDefaults   bool
+	jsonNames      bool
+	enumsAsInts    bool
+	int64AsNumbers bool
+	showUnknown    bool
+
+	commitMode     string
+	commitInterval time.Duration
 )
 
 var rootCmd = &cobra.Command{
@@ -50,9 +79,28 @@ func Execute() {
 
 func init() {
 	// Persistent flags (available to all commands)
-	rootCmd.PersistentFlags().StringVarP(&protoDir, "proto", "p", "buf.yaml", "Path to buf.yaml file or protobuf descriptor set (.desc/.pb/.protoset)")
+	rootCmd.PersistentFlags().StringVarP(&protoDir, "proto", "p", "buf.yaml", "Path to buf.yaml, a .proto file or directory, a protobuf descriptor set (.desc/.pb/.protoset), or a BSR module (buf.build/owner/repo[:ref])")
+	rootCmd.PersistentFlags().StringSliceVarP(&importPaths, "import-path", "I", nil, "Directories to search for imports when compiling .proto files in-process")
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "Rebuild descriptors instead of using the descriptor cache")
+	rootCmd.PersistentFlags().BoolVar(&strict, "strict", false, "Fail if any proto file cannot be loaded instead of skipping it")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Verbose output")
 
 	// Add list command
 	rootCmd.AddCommand(listCmd)
 }
+
+// descriptorCacheDir returns the directory to cache descriptors in, or "" if
+// caching is disabled or no cache directory is available.
+func descriptorCacheDir() string {
+	if noCache {
+		return ""
+	}
+	dir, err := decoder.DefaultCacheDir()
+	if err != nil {
+		if verbose {
+			fmt.Fprintf(os.Stderr, "Descriptor cache disabled: %v\n", err)
+		}
+		return ""
+	}
+	return dir
+}
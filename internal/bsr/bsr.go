@@ -0,0 +1,223 @@
+// Package bsr downloads images of modules hosted on the Buf Schema Registry
+// or a compatible server.
+package bsr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"gopkg.in/yaml.v3"
+)
+
+// requestTimeout bounds a single image download.
+const requestTimeout = 60 * time.Second
+
+// getImagePath is the Connect endpoint that serves module images.
+const getImagePath = "/buf.alpha.registry.v1alpha1.ImageService/GetImage"
+
+// ModuleRef names a module on a registry, such as buf.build/acme/events:main.
+type ModuleRef struct {
+	Remote     string
+	Owner      string
+	Repository string
+	// Reference is a branch, tag, or commit. Empty means the latest commit
+	// on the default branch.
+	Reference string
+}
+
+// ParseModuleRef parses remote/owner/repository[:reference]. It reports false
+// if s does not look like a module reference, e.g. because it is a file path.
+func ParseModuleRef(s string) (ModuleRef, bool) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 3 {
+		return ModuleRef{}, false
+	}
+	// A remote is a host name, so it has a dot or a port; this keeps
+	// relative paths like protos/acme/events from being mistaken for refs.
+	remote := parts[0]
+	if !strings.ContainsAny(remote, ".:") || strings.HasPrefix(remote, ".") {
+		return ModuleRef{}, false
+	}
+	repository, reference, _ := strings.Cut(parts[2], ":")
+	ref := ModuleRef{Remote: remote, Owner: parts[1], Repository: repository, Reference: reference}
+	if ref.Owner == "" || ref.Repository == "" {
+		return ModuleRef{}, false
+	}
+	return ref, true
+}
+
+// IsCommit reports whether reference names a commit rather than a branch or
+// tag. Commit IDs are 32 lowercase hex characters.
+func IsCommit(reference string) bool {
+	if len(reference) != 32 {
+		return false
+	}
+	for _, c := range reference {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the reference in remote/owner/repository[:reference] form.
+func (r ModuleRef) String() string {
+	s := r.Remote + "/" + r.Owner + "/" + r.Repository
+	if r.Reference != "" {
+		s += ":" + r.Reference
+	}
+	return s
+}
+
+// Client downloads module images.
+type Client struct {
+	// HTTPClient sends the requests; replace it to talk to a test server.
+	HTTPClient *http.Client
+	// Token authenticates requests to private modules, if set.
+	Token string
+}
+
+// NewClient returns a client authenticated with the BUF_TOKEN environment
+// variable, as used by the buf CLI.
+func NewClient() *Client {
+	return &Client{
+		HTTPClient: &http.Client{Timeout: requestTimeout},
+		Token:      os.Getenv("BUF_TOKEN"),
+	}
+}
+
+// Image downloads the image of ref, including its imports, and returns it as
+// a serialized FileDescriptorSet.
+func (c *Client) Image(ctx context.Context, ref ModuleRef) ([]byte, error) {
+	// GetImageRequest{owner = 1, repository = 2, reference = 3}
+	var body []byte
+	body = protowire.AppendTag(body, 1, protowire.BytesType)
+	body = protowire.AppendString(body, ref.Owner)
+	body = protowire.AppendTag(body, 2, protowire.BytesType)
+	body = protowire.AppendString(body, ref.Repository)
+	if ref.Reference != "" {
+		body = protowire.AppendTag(body, 3, protowire.BytesType)
+		body = protowire.AppendString(body, ref.Reference)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://"+ref.Remote+getImagePath, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/proto")
+	req.Header.Set("Connect-Protocol-Version", "1")
+	if token := tokenFor(c.Token, ref.Remote); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", ref, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", ref, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var connectErr struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &connectErr) == nil && connectErr.Code != "" {
+			return nil, fmt.Errorf("failed to download %s: %s: %s", ref, connectErr.Code, connectErr.Message)
+		}
+		return nil, fmt.Errorf("failed to download %s: %s", ref, resp.Status)
+	}
+
+	// GetImageResponse{image = 1}. An image is wire-compatible with a
+	// FileDescriptorSet; buf's extra per-file metadata is ignored as unknown.
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, fmt.Errorf("invalid image response for %s: %w", ref, protowire.ParseError(n))
+		}
+		data = data[n:]
+		if num == 1 && typ == protowire.BytesType {
+			image, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return nil, fmt.Errorf("invalid image response for %s: %w", ref, protowire.ParseError(n))
+			}
+			return image, nil
+		}
+		n = protowire.ConsumeFieldValue(num, typ, data)
+		if n < 0 {
+			return nil, fmt.Errorf("invalid image response for %s: %w", ref, protowire.ParseError(n))
+		}
+		data = data[n:]
+	}
+	return nil, fmt.Errorf("empty image response for %s", ref)
+}
+
+// tokenFor picks the token for remote from BUF_TOKEN, which is either a
+// single token or a list of token@remote entries.
+func tokenFor(tokens, remote string) string {
+	if !strings.Contains(tokens, "@") {
+		return tokens
+	}
+	for _, entry := range strings.Split(tokens, ",") {
+		token, host, ok := strings.Cut(strings.TrimSpace(entry), "@")
+		if ok && host == remote {
+			return token
+		}
+	}
+	return ""
+}
+
+// lockFile is the subset of buf.lock, in either the v1 or v2 layout, that
+// pins dependencies to commits.
+type lockFile struct {
+	Deps []struct {
+		// v2
+		Name string `yaml:"name"`
+		// v1
+		Remote     string `yaml:"remote"`
+		Owner      string `yaml:"owner"`
+		Repository string `yaml:"repository"`
+
+		Commit string `yaml:"commit"`
+	} `yaml:"deps"`
+}
+
+// PinnedReference returns the commit that the buf.lock at path pins ref's
+// module to. It reports false if the module is not locked there or there is
+// no buf.lock.
+func PinnedReference(path string, ref ModuleRef) (string, bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var lock lockFile
+	if err := yaml.Unmarshal(data, &lock); err != nil {
+		return "", false, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	name := ref.Remote + "/" + ref.Owner + "/" + ref.Repository
+	for _, dep := range lock.Deps {
+		depName := dep.Name
+		if depName == "" {
+			depName = dep.Remote + "/" + dep.Owner + "/" + dep.Repository
+		}
+		if depName == name && dep.Commit != "" {
+			return dep.Commit, true, nil
+		}
+	}
+	return "", false, nil
+}
@@ -0,0 +1,149 @@
+package bsr
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestParseModuleRef(t *testing.T) {
+	tests := []struct {
+		in   string
+		want ModuleRef
+		ok   bool
+	}{
+		{"buf.build/acme/events", ModuleRef{"buf.build", "acme", "events", ""}, true},
+		{"buf.build/acme/events:main", ModuleRef{"buf.build", "acme", "events", "main"}, true},
+		{"localhost:8080/acme/events:v1.2.0", ModuleRef{"localhost:8080", "acme", "events", "v1.2.0"}, true},
+		{"buf.yaml", ModuleRef{}, false},
+		{"protos/acme/events", ModuleRef{}, false},
+		{"./acme/events", ModuleRef{}, false},
+		{"buf.build/acme", ModuleRef{}, false},
+		{"buf.build//events", ModuleRef{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseModuleRef(tt.in)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParseModuleRef(%q) = %+v, %v; want %+v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestIsCommit(t *testing.T) {
+	for ref, want := range map[string]bool{
+		"0123456789abcdef0123456789abcdef": true,
+		"main":                             false,
+		"v1.2.0":                           false,
+		"0123456789ABCDEF0123456789ABCDEF": false,
+		"":                                 false,
+	} {
+		if got := IsCommit(ref); got != want {
+			t.Errorf("IsCommit(%q) = %v, want %v", ref, got, want)
+		}
+	}
+}
+
+// newFakeRegistry serves image for acme/events and not_found for anything
+// else, recording the reference of the last request.
+func newFakeRegistry(t *testing.T, image []byte, lastRef *string) *httptest.Server {
+	t.Helper()
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != getImagePath || r.Header.Get("Content-Type") != "application/proto" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, `{"code":"unauthenticated","message":"missing token"}`)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		fields := make(map[protowire.Number]string)
+		for len(body) > 0 {
+			num, _, n := protowire.ConsumeTag(body)
+			v, m := protowire.ConsumeBytes(body[n:])
+			fields[num] = string(v)
+			body = body[n+m:]
+		}
+		*lastRef = fields[3]
+		if fields[1] != "acme" || fields[2] != "events" {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"code":"not_found","message":"repository not found"}`)
+			return
+		}
+
+		var resp []byte
+		resp = protowire.AppendTag(resp, 1, protowire.BytesType)
+		resp = protowire.AppendBytes(resp, image)
+		w.Header().Set("Content-Type", "application/proto")
+		w.Write(resp)
+	}))
+}
+
+func TestClientImage(t *testing.T) {
+	image := []byte("fake image")
+	var lastRef string
+	server := newFakeRegistry(t, image, &lastRef)
+	defer server.Close()
+
+	remote := strings.TrimPrefix(server.URL, "https://")
+	client := &Client{HTTPClient: server.Client(), Token: "secret@" + remote}
+
+	got, err := client.Image(context.Background(), ModuleRef{Remote: remote, Owner: "acme", Repository: "events", Reference: "main"})
+	if err != nil {
+		t.Fatalf("Image failed: %v", err)
+	}
+	if string(got) != string(image) {
+		t.Errorf("Image = %q, want %q", got, image)
+	}
+	if lastRef != "main" {
+		t.Errorf("requested reference %q, want main", lastRef)
+	}
+
+	_, err = client.Image(context.Background(), ModuleRef{Remote: remote, Owner: "acme", Repository: "missing"})
+	if err == nil || !strings.Contains(err.Error(), "repository not found") {
+		t.Errorf("Image of a missing module error = %v, want not_found", err)
+	}
+
+	client.Token = ""
+	_, err = client.Image(context.Background(), ModuleRef{Remote: remote, Owner: "acme", Repository: "events"})
+	if err == nil || !strings.Contains(err.Error(), "unauthenticated") {
+		t.Errorf("Image without a token error = %v, want unauthenticated", err)
+	}
+}
+
+func TestPinnedReference(t *testing.T) {
+	dir := t.TempDir()
+	ref := ModuleRef{Remote: "buf.build", Owner: "acme", Repository: "events"}
+
+	locks := map[string]string{
+		"v1": "version: v1\ndeps:\n  - remote: buf.build\n    owner: acme\n    repository: events\n    commit: 0123abcd\n",
+		"v2": "version: v2\ndeps:\n  - name: buf.build/googleapis/googleapis\n    commit: ffff\n  - name: buf.build/acme/events\n    commit: 0123abcd\n    digest: b5:00\n",
+	}
+	for version, content := range locks {
+		path := filepath.Join(dir, version+".lock")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		commit, ok, err := PinnedReference(path, ref)
+		if err != nil || !ok || commit != "0123abcd" {
+			t.Errorf("%s: PinnedReference = %q, %v, %v; want 0123abcd", version, commit, ok, err)
+		}
+	}
+
+	other := ModuleRef{Remote: "buf.build", Owner: "acme", Repository: "billing"}
+	if _, ok, err := PinnedReference(filepath.Join(dir, "v2.lock"), other); ok || err != nil {
+		t.Errorf("PinnedReference of an unlocked module = %v, %v; want false, nil", ok, err)
+	}
+	if _, ok, err := PinnedReference(filepath.Join(dir, "buf.lock"), ref); ok || err != nil {
+		t.Errorf("PinnedReference without buf.lock = %v, %v; want false, nil", ok, err)
+	}
+}
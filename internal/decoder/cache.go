@@ -0,0 +1,163 @@
+package decoder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// cacheVersion is mixed into every cache key, so that changing how
+// descriptors are built invalidates entries written by older versions.
+const cacheVersion = "buf-kcat descriptors v1"
+
+// cacheSubdir holds the cached descriptor sets inside the cache directory.
+const cacheSubdir = "descriptors"
+
+// cacheExt is the extension of finished cache entries. Entries are written
+// to temporary files first, which interrupted runs may leave behind.
+const cacheExt = ".binpb"
+
+// DefaultCacheDir returns the directory descriptor sets are cached in:
+// $XDG_CACHE_HOME/buf-kcat on Linux, or the platform's equivalent.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	return filepath.Join(dir, "buf-kcat"), nil
+}
+
+// ClearCache removes every descriptor set cached in dir and returns how many
+// were removed. Leftovers of interrupted cache writes are removed as well but
+// not counted.
+func ClearCache(dir string) (int, error) {
+	descriptors := filepath.Join(dir, cacheSubdir)
+	entries, err := os.ReadDir(descriptors)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read cache: %w", err)
+	}
+	removed := 0
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == cacheExt {
+			removed++
+		}
+	}
+	if err := os.RemoveAll(descriptors); err != nil {
+		return 0, fmt.Errorf("failed to clear cache: %w", err)
+	}
+	return removed, nil
+}
+
+// sourceKey hashes everything that determines the descriptors built from
+// dirs: the build kind, the import paths, and the path and content of every
+// .proto file and buf configuration file below dirs and the import paths.
+func sourceKey(kind string, dirs []string, importPaths []string) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00", cacheVersion, kind, strings.Join(importPaths, "\x00"))
+	for i, dir := range append(append([]string{}, dirs...), importPaths...) {
+		fmt.Fprintf(h, "root %d\x00", i)
+		err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() {
+				// Skip .git and similar directories that cannot hold sources.
+				if path != dir && strings.HasPrefix(entry.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !isSourceFile(entry.Name()) {
+				return nil
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			fmt.Fprintf(h, "%s\x00", filepath.ToSlash(rel))
+			_, err = io.Copy(h, f)
+			h.Write([]byte{0})
+			return err
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to hash sources in %s: %w", dir, err)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// isSourceFile reports whether a file named name can affect a build.
+func isSourceFile(name string) bool {
+	switch name {
+	case "buf.yaml", "buf.lock", "buf.work.yaml", "buf.gen.yaml":
+		return true
+	}
+	return filepath.Ext(name) == ".proto"
+}
+
+// loadBuilt loads the descriptor set that build produces, reusing the one
+// cached under key if there is one. An empty key or cache directory disables
+// the cache.
+func (d *Decoder) loadBuilt(key string, build func() ([]byte, error)) error {
+	var path string
+	if d.cacheDir != "" && key != "" {
+		path = filepath.Join(d.cacheDir, cacheSubdir, key+cacheExt)
+		if data, err := os.ReadFile(path); err == nil && isDescriptorSet(data) {
+			return d.loadDescriptorSet(data)
+		}
+	}
+
+	data, err := build()
+	if err != nil {
+		return err
+	}
+	if path != "" {
+		// A cache that cannot be written only costs the next run a rebuild.
+		_ = writeCacheFile(path, data)
+	}
+	return d.loadDescriptorSet(data)
+}
+
+// isDescriptorSet reports whether data is a non-empty FileDescriptorSet, to
+// guard against truncated cache entries.
+func isDescriptorSet(data []byte) bool {
+	var fdSet descriptorpb.FileDescriptorSet
+	return proto.Unmarshal(data, &fdSet) == nil && len(fdSet.File) > 0
+}
+
+// writeCacheFile writes data to path atomically, so that concurrent runs
+// never read a partially written entry.
+func writeCacheFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,102 @@
+package decoder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func cacheEntries(t *testing.T, cacheDir string) []string {
+	t.Helper()
+	entries, _ := filepath.Glob(filepath.Join(cacheDir, cacheSubdir, "*"+cacheExt))
+	return entries
+}
+
+func TestDescriptorCache(t *testing.T) {
+	source, err := os.ReadFile("../../test/example/proto/events.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	protoDir := t.TempDir()
+	writeFiles(t, protoDir, map[string]string{"events.proto": string(source)})
+	cacheDir := t.TempDir()
+	opts := Options{CacheDir: cacheDir}
+
+	if _, err := NewDecoderWithOptions(protoDir, "", opts); err != nil {
+		t.Fatalf("NewDecoderWithOptions failed: %v", err)
+	}
+	entries := cacheEntries(t, cacheDir)
+	if len(entries) != 1 {
+		t.Fatalf("cache has %d entries after the first build, want 1", len(entries))
+	}
+
+	// Replace the entry to prove that the next run loads it instead of
+	// compiling the sources again.
+	marker := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:        proto.String("marker.proto"),
+		Package:     proto.String("cached"),
+		Syntax:      proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{Name: proto.String("Marker")}},
+	}}}
+	data, err := proto.Marshal(marker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(entries[0], data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	dec, err := NewDecoderWithOptions(protoDir, "", opts)
+	if err != nil {
+		t.Fatalf("NewDecoderWithOptions from cache failed: %v", err)
+	}
+	if _, ok := dec.GetMessageTypes()["cached.Marker"]; !ok {
+		t.Error("second run did not load the cached descriptors")
+	}
+
+	// A truncated entry is rebuilt rather than trusted.
+	if err := os.WriteFile(entries[0], data[:3], 0644); err != nil {
+		t.Fatal(err)
+	}
+	dec, err = NewDecoderWithOptions(protoDir, "", opts)
+	if err != nil {
+		t.Fatalf("NewDecoderWithOptions with a corrupt entry failed: %v", err)
+	}
+	if _, ok := dec.GetMessageTypes()["events.OrderEvent"]; !ok {
+		t.Error("corrupt cache entry was not rebuilt")
+	}
+
+	// Changing a source file changes the key.
+	writeFiles(t, protoDir, map[string]string{"extra.proto": "syntax = \"proto3\";\npackage extra;\nmessage Extra {}\n"})
+	dec, err = NewDecoderWithOptions(protoDir, "", opts)
+	if err != nil {
+		t.Fatalf("NewDecoderWithOptions after a change failed: %v", err)
+	}
+	if _, ok := dec.GetMessageTypes()["extra.Extra"]; !ok {
+		t.Error("changed sources were served from a stale cache entry")
+	}
+	if n := len(cacheEntries(t, cacheDir)); n != 2 {
+		t.Errorf("cache has %d entries after a change, want 2", n)
+	}
+
+	// A write interrupted before its rename leaves a temporary file behind.
+	leftover := filepath.Join(cacheDir, cacheSubdir, ".tmp-123456")
+	if err := os.WriteFile(leftover, data[:3], 0644); err != nil {
+		t.Fatal(err)
+	}
+	removed, err := ClearCache(cacheDir)
+	if err != nil || removed != 2 {
+		t.Errorf("ClearCache = %d, %v; want 2, nil", removed, err)
+	}
+	if n := len(cacheEntries(t, cacheDir)); n != 0 {
+		t.Errorf("cache has %d entries after ClearCache", n)
+	}
+	if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+		t.Errorf("temporary file survived ClearCache: %v", err)
+	}
+	if removed, err := ClearCache(cacheDir); err != nil || removed != 0 {
+		t.Errorf("ClearCache of an empty cache = %d, %v; want 0, nil", removed, err)
+	}
+}
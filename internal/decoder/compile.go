@@ -0,0 +1,181 @@
+package decoder
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"gopkg.in/yaml.v3"
+)
+
+// sourceRoot is a directory of .proto files that are compiled, named
+// relative to the directory.
+type sourceRoot struct {
+	dir      string
+	excludes []string
+}
+
+// bufConfig is the subset of buf.yaml, in either the v1 or v2 layout, that
+// locates a module's .proto files.
+type bufConfig struct {
+	Version string `yaml:"version"`
+	// v1
+	Build struct {
+		Excludes []string `yaml:"excludes"`
+	} `yaml:"build"`
+	// v2
+	Modules []struct {
+		Path     string   `yaml:"path"`
+		Excludes []string `yaml:"excludes"`
+	} `yaml:"modules"`
+}
+
+// bufModuleRoots returns the source roots of the module or workspace that the
+// buf.yaml at path describes.
+func bufModuleRoots(path string) ([]sourceRoot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var cfg bufConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	abs := func(paths []string) []string {
+		out := make([]string, len(paths))
+		for i, p := range paths {
+			out[i] = filepath.Join(dir, p)
+		}
+		return out
+	}
+
+	switch cfg.Version {
+	case "v2":
+		if len(cfg.Modules) == 0 {
+			return []sourceRoot{{dir: dir}}, nil
+		}
+		roots := make([]sourceRoot, 0, len(cfg.Modules))
+		for _, m := range cfg.Modules {
+			// v2 excludes are relative to the workspace, like the module path.
+			roots = append(roots, sourceRoot{dir: filepath.Join(dir, m.Path), excludes: abs(m.Excludes)})
+		}
+		return roots, nil
+	case "", "v1", "v1beta1":
+		return []sourceRoot{{dir: dir, excludes: abs(cfg.Build.Excludes)}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported buf.yaml version %q in %s", cfg.Version, path)
+	}
+}
+
+// compileSources compiles every .proto file under roots in-process and
+// returns them, with all of their imports, as a serialized FileDescriptorSet.
+func compileSources(roots []sourceRoot, importPaths []string) ([]byte, error) {
+	var files []string
+	searchPaths := make([]string, 0, len(roots)+len(importPaths))
+	for _, root := range roots {
+		found, err := findProtoFiles(root)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, found...)
+		searchPaths = append(searchPaths, root.dir)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no .proto files found")
+	}
+	return compileFiles(files, append(searchPaths, importPaths...))
+}
+
+// compileProtoFile compiles a single .proto file in-process. It is named
+// relative to the import path that contains it, or else its directory.
+func compileProtoFile(path string, importPaths []string) ([]byte, error) {
+	for _, dir := range importPaths {
+		rel, err := filepath.Rel(dir, path)
+		if err == nil && !strings.HasPrefix(rel, "..") {
+			return compileFiles([]string{filepath.ToSlash(rel)}, importPaths)
+		}
+	}
+	searchPaths := append([]string{filepath.Dir(path)}, importPaths...)
+	return compileFiles([]string{filepath.Base(path)}, searchPaths)
+}
+
+// compileFiles compiles files and returns them, with all of their imports,
+// as a serialized FileDescriptorSet. Imports are resolved against
+// searchPaths, then the well-known types bundled with protocompile.
+func compileFiles(files []string, searchPaths []string) ([]byte, error) {
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			ImportPaths: searchPaths,
+		}),
+	}
+	compiled, err := compiler.Compile(context.Background(), files...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile protos: %w", err)
+	}
+
+	// Dependencies come before the files that import them, as in the
+	// output of buf build and protoc --include_imports.
+	var fdSet descriptorpb.FileDescriptorSet
+	seen := make(map[string]bool)
+	var add func(fd protoreflect.FileDescriptor)
+	add = func(fd protoreflect.FileDescriptor) {
+		if seen[fd.Path()] {
+			return
+		}
+		seen[fd.Path()] = true
+		imports := fd.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			add(imports.Get(i).FileDescriptor)
+		}
+		fdSet.File = append(fdSet.File, protodesc.ToFileDescriptorProto(fd))
+	}
+	for _, fd := range compiled {
+		add(fd)
+	}
+
+	return proto.Marshal(&fdSet)
+}
+
+// findProtoFiles returns the sorted paths of the .proto files under root,
+// relative to root.
+func findProtoFiles(root sourceRoot) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			for _, exclude := range root.excludes {
+				if filepath.Clean(path) == filepath.Clean(exclude) {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".proto" {
+			return nil
+		}
+		rel, err := filepath.Rel(root.dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find .proto files in %s: %w", root.dir, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
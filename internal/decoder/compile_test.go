@@ -0,0 +1,80 @@
+package decoder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates files under dir from a map of relative path to content.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCompileExampleModule(t *testing.T) {
+	roots, err := bufModuleRoots("../../test/example/buf.yaml")
+	if err != nil {
+		t.Fatalf("bufModuleRoots failed: %v", err)
+	}
+	if len(roots) != 1 || filepath.Base(roots[0].dir) != "proto" {
+		t.Fatalf("bufModuleRoots = %+v, want the proto module", roots)
+	}
+
+	for name, protoPath := range map[string]string{
+		"directory":  "../../test/example/proto",
+		"proto file": "../../test/example/proto/events.proto",
+	} {
+		t.Run(name, func(t *testing.T) {
+			dec, err := NewDecoder(protoPath, "events.OrderEvent")
+			if err != nil {
+				t.Fatalf("NewDecoder failed: %v", err)
+			}
+			for _, typeName := range []string{"events.OrderEvent", "google.protobuf.Timestamp"} {
+				if _, ok := dec.GetMessageTypes()[typeName]; !ok {
+					t.Errorf("compiled descriptors are missing %s", typeName)
+				}
+			}
+		})
+	}
+}
+
+func TestCompileWithImportPaths(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"module/buf.yaml":            "version: v1\nbuild:\n  excludes:\n    - broken\n",
+		"module/shop/order.proto":    "syntax = \"proto3\";\npackage shop;\nimport \"common/money.proto\";\nmessage Order { common.Money total = 1; }\n",
+		"module/broken/broken.proto": "this is not protobuf",
+		"vendor/common/money.proto":  "syntax = \"proto3\";\npackage common;\nmessage Money { int64 units = 1; }\n",
+	})
+	bufYaml := filepath.Join(dir, "module", "buf.yaml")
+
+	roots, err := bufModuleRoots(bufYaml)
+	if err != nil {
+		t.Fatalf("bufModuleRoots failed: %v", err)
+	}
+	if _, err := compileSources(roots, nil); err == nil || !strings.Contains(err.Error(), "common/money.proto") {
+		t.Errorf("compileSources without import paths error = %v, want missing common/money.proto", err)
+	}
+
+	dec, err := NewDecoderWithOptions(bufYaml, "shop.Order", Options{ImportPaths: []string{filepath.Join(dir, "vendor")}})
+	if err != nil {
+		t.Fatalf("NewDecoderWithOptions failed: %v", err)
+	}
+	if _, ok := dec.GetMessageTypes()["common.Money"]; !ok {
+		t.Error("imported common.Money was not loaded")
+	}
+
+	if _, err := NewDecoder(filepath.Join(dir, "module", "broken"), ""); err == nil {
+		t.Error("NewDecoder compiled an invalid .proto file")
+	}
+}
@@ -0,0 +1,169 @@
+package decoder
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// confluentMagic is the first byte of every payload written by a Confluent
+// Schema Registry serializer. A plain protobuf message never starts with it,
+// since field number 0 is invalid.
+const confluentMagic = 0x00
+
+// ConfluentFrame is a payload in the Confluent Schema Registry wire format:
+// the magic byte, a 4-byte big-endian schema ID, the message indexes that
+// locate the message type within the schema, and the protobuf payload.
+type ConfluentFrame struct {
+	SchemaID uint32
+	// Indexes is the path to the message type through the schema's
+	// top-level and nested messages, e.g. [1 0] for the first message nested
+	// in the second top-level message.
+	Indexes []int
+	Payload []byte
+}
+
+// SchemaSource resolves Confluent schema IDs to compiled schemas.
+type SchemaSource interface {
+	Schema(id uint32) (protoreflect.FileDescriptor, error)
+}
+
+// ParseConfluentFrame splits data into its Confluent framing and payload. It
+// reports false if data is not in the Confluent wire format.
+func ParseConfluentFrame(data []byte) (ConfluentFrame, bool) {
+	if len(data) < 5 || data[0] != confluentMagic {
+		return ConfluentFrame{}, false
+	}
+	frame := ConfluentFrame{SchemaID: binary.BigEndian.Uint32(data[1:5])}
+	rest := data[5:]
+
+	count, n := protowire.ConsumeVarint(rest)
+	if n < 0 {
+		return ConfluentFrame{}, false
+	}
+	rest = rest[n:]
+	length := protowire.DecodeZigZag(count)
+	// A count of zero is the common shorthand for [0], the first message.
+	if length == 0 {
+		frame.Indexes = []int{0}
+		frame.Payload = rest
+		return frame, true
+	}
+	if length < 0 || length > int64(len(rest)) {
+		return ConfluentFrame{}, false
+	}
+	for i := int64(0); i < length; i++ {
+		v, n := protowire.ConsumeVarint(rest)
+		if n < 0 {
+			return ConfluentFrame{}, false
+		}
+		index := protowire.DecodeZigZag(v)
+		if index < 0 || index > int64(len(data)) {
+			return ConfluentFrame{}, false
+		}
+		frame.Indexes = append(frame.Indexes, int(index))
+		rest = rest[n:]
+	}
+	frame.Payload = rest
+	return frame, true
+}
+
+// Marshal encodes the frame in the Confluent wire format.
+func (f ConfluentFrame) Marshal() []byte {
+	data := make([]byte, 5, 6+len(f.Payload))
+	data[0] = confluentMagic
+	binary.BigEndian.PutUint32(data[1:5], f.SchemaID)
+	if len(f.Indexes) == 0 || (len(f.Indexes) == 1 && f.Indexes[0] == 0) {
+		data = protowire.AppendVarint(data, 0)
+	} else {
+		data = protowire.AppendVarint(data, protowire.EncodeZigZag(int64(len(f.Indexes))))
+		for _, i := range f.Indexes {
+			data = protowire.AppendVarint(data, protowire.EncodeZigZag(int64(i)))
+		}
+	}
+	return append(data, f.Payload...)
+}
+
+// ConfluentIndexes returns the message indexes that locate typeName within
+// fd, the inverse of the lookup DecodeConfluent performs.
+func ConfluentIndexes(fd protoreflect.FileDescriptor, typeName string) ([]int, error) {
+	if indexes := findMessageIndexes(fd.Messages(), protoreflect.FullName(typeName)); indexes != nil {
+		return indexes, nil
+	}
+	return nil, fmt.Errorf("message type %s is not declared in %s", typeName, fd.Path())
+}
+
+func findMessageIndexes(messages protoreflect.MessageDescriptors, name protoreflect.FullName) []int {
+	for i := 0; i < messages.Len(); i++ {
+		md := messages.Get(i)
+		if md.FullName() == name {
+			return []int{i}
+		}
+		if nested := findMessageIndexes(md.Messages(), name); nested != nil {
+			return append([]int{i}, nested...)
+		}
+	}
+	return nil
+}
+
+// SetSchemaSource makes DecodeConfluent look up the schema of every framed
+// payload by its ID instead of using the locally loaded descriptors.
+func (d *Decoder) SetSchemaSource(src SchemaSource) {
+	d.schemas = src
+}
+
+// DecodeConfluent decodes a Confluent-framed payload. With a schema source
+// the message type comes from the registered schema; otherwise the frame's
+// message indexes are resolved within the file that declares typeName.
+func (d *Decoder) DecodeConfluent(frame ConfluentFrame, typeName string) ([]byte, string, error) {
+	var fd protoreflect.FileDescriptor
+	switch {
+	case d.schemas != nil:
+		schema, err := d.schemas.Schema(frame.SchemaID)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to fetch schema %d: %w", frame.SchemaID, err)
+		}
+		fd = schema
+	case typeName == "":
+		return nil, "", fmt.Errorf("message type is required for schema %d", frame.SchemaID)
+	case typeName == AutoType:
+		return d.decodeWithType(frame.Payload, AutoType)
+	default:
+		msgType, ok := d.messageTypes[typeName]
+		if !ok {
+			return nil, "", d.unknownTypeError(typeName)
+		}
+		fd = msgType.Descriptor().ParentFile()
+	}
+
+	md, err := messageAtIndexes(fd, frame.Indexes)
+	if err != nil {
+		return nil, "", err
+	}
+	jsonData, err := d.marshalPayload(frame.Payload, dynamicpb.NewMessageType(md), true)
+	if err != nil {
+		return nil, "", err
+	}
+	return jsonData, string(md.FullName()), nil
+}
+
+// messageAtIndexes follows Confluent message indexes through the messages
+// declared in fd.
+func messageAtIndexes(fd protoreflect.FileDescriptor, indexes []int) (protoreflect.MessageDescriptor, error) {
+	if len(indexes) == 0 {
+		return nil, fmt.Errorf("empty message indexes")
+	}
+	var md protoreflect.MessageDescriptor
+	messages := fd.Messages()
+	for _, i := range indexes {
+		if i >= messages.Len() {
+			return nil, fmt.Errorf("message indexes %v do not exist in %s", indexes, fd.Path())
+		}
+		md = messages.Get(i)
+		messages = md.Messages()
+	}
+	return md, nil
+}
@@ -0,0 +1,153 @@
+package decoder
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// confluentFrame builds a payload in the Confluent wire format.
+func confluentFrame(id uint32, indexes []int, payload []byte) []byte {
+	return ConfluentFrame{SchemaID: id, Indexes: indexes, Payload: payload}.Marshal()
+}
+
+func TestConfluentFrameMarshal(t *testing.T) {
+	payload := []byte{0x0a, 0x01, 'x'}
+	first := confluentFrame(42, []int{0}, payload)
+	want := append([]byte{0, 0, 0, 0, 42, 0}, payload...)
+	if string(first) != string(want) {
+		t.Errorf("Marshal() = %x, want %x", first, want)
+	}
+
+	nested := confluentFrame(1, []int{2, 1}, payload)
+	want = append([]byte{0, 0, 0, 0, 1, 4, 4, 2}, payload...)
+	if string(nested) != string(want) {
+		t.Errorf("Marshal() = %x, want %x", nested, want)
+	}
+}
+
+func TestConfluentIndexes(t *testing.T) {
+	dec, err := NewDecoder(testDescriptorSet, "")
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	fd := dec.GetMessageTypes()["events.OrderEvent"].Descriptor().ParentFile()
+
+	indexes, err := ConfluentIndexes(fd, "events.OrderEvent")
+	if err != nil {
+		t.Fatalf("ConfluentIndexes failed: %v", err)
+	}
+	if fmt.Sprint(indexes) != "[2]" {
+		t.Errorf("ConfluentIndexes = %v, want [2]", indexes)
+	}
+	if _, err := ConfluentIndexes(fd, "events.Missing"); err == nil {
+		t.Error("ConfluentIndexes found an undeclared message type")
+	}
+}
+
+func TestParseConfluentFrame(t *testing.T) {
+	payload := []byte{0x0a, 0x01, 'x'}
+	tests := []struct {
+		name    string
+		data    []byte
+		ok      bool
+		id      uint32
+		indexes []int
+	}{
+		{"first message", confluentFrame(42, []int{0}, payload), true, 42, []int{0}},
+		{"nested message", confluentFrame(7, []int{2, 1}, payload), true, 7, []int{2, 1}},
+		{"plain protobuf", payload, false, 0, nil},
+		{"too short", []byte{0, 0, 0}, false, 0, nil},
+		{"truncated indexes", []byte{0, 0, 0, 0, 1, 0x04}, false, 0, nil},
+		{"negative index", []byte{0, 0, 0, 0, 1, 0x02, 0x01}, false, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, ok := ParseConfluentFrame(tt.data)
+			if ok != tt.ok {
+				t.Fatalf("ParseConfluentFrame ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if frame.SchemaID != tt.id {
+				t.Errorf("SchemaID = %d, want %d", frame.SchemaID, tt.id)
+			}
+			if fmt.Sprint(frame.Indexes) != fmt.Sprint(tt.indexes) {
+				t.Errorf("Indexes = %v, want %v", frame.Indexes, tt.indexes)
+			}
+			if string(frame.Payload) != string(payload) {
+				t.Errorf("Payload = %x, want %x", frame.Payload, payload)
+			}
+		})
+	}
+}
+
+// fakeSchemas serves a fixed file for every schema ID.
+type fakeSchemas struct {
+	fd  protoreflect.FileDescriptor
+	ids []uint32
+}
+
+func (f *fakeSchemas) Schema(id uint32) (protoreflect.FileDescriptor, error) {
+	f.ids = append(f.ids, id)
+	return f.fd, nil
+}
+
+func TestDecodeConfluent(t *testing.T) {
+	dec, err := NewDecoder(testDescriptorSet, "events.UserEvent")
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+
+	var order []byte
+	order = protowire.AppendTag(order, 1, protowire.BytesType)
+	order = protowire.AppendString(order, "order-1")
+	order = protowire.AppendTag(order, 3, protowire.BytesType)
+	order = protowire.AppendString(order, "PAID")
+
+	// Index 2 is OrderEvent, the third message in events.proto.
+	frame, ok := ParseConfluentFrame(confluentFrame(5, []int{2}, order))
+	if !ok {
+		t.Fatal("ParseConfluentFrame rejected a valid frame")
+	}
+
+	decoded, typeName, err := dec.DecodeConfluent(frame, "events.UserEvent")
+	if err != nil {
+		t.Fatalf("DecodeConfluent failed: %v", err)
+	}
+	if typeName != "events.OrderEvent" {
+		t.Errorf("type = %s, want events.OrderEvent", typeName)
+	}
+	// protojson varies its whitespace, so compare the parsed value.
+	var value map[string]any
+	if err := json.Unmarshal(decoded, &value); err != nil || value["order_id"] != "order-1" {
+		t.Errorf("decoded = %s, want order_id order-1", decoded)
+	}
+
+	if _, _, err := dec.DecodeConfluent(frame, ""); err == nil {
+		t.Error("DecodeConfluent succeeded without a message type or schema source")
+	}
+	frame.Indexes = []int{99}
+	if _, _, err := dec.DecodeConfluent(frame, "events.UserEvent"); err == nil {
+		t.Error("DecodeConfluent accepted out of range message indexes")
+	}
+
+	// A schema source overrides the locally selected message type.
+	schemas := &fakeSchemas{fd: dec.GetMessageTypes()["events.OrderEvent"].Descriptor().ParentFile()}
+	registryDec, err := NewDecoder("", "")
+	if err != nil {
+		t.Fatalf("NewDecoder without descriptors failed: %v", err)
+	}
+	registryDec.SetSchemaSource(schemas)
+	frame.Indexes = []int{2}
+	if _, typeName, err := registryDec.DecodeConfluent(frame, ""); err != nil || typeName != "events.OrderEvent" {
+		t.Errorf("DecodeConfluent with schema source = %s, %v; want events.OrderEvent", typeName, err)
+	}
+	if len(schemas.ids) != 1 || schemas.ids[0] != 5 {
+		t.Errorf("schema source asked for %v, want [5]", schemas.ids)
+	}
+}
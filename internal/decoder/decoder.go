@@ -2,12 +2,18 @@ package decoder
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
 
-	"google.golang.org/protobuf/encoding/protojson"
+	"github.com/HurSungYun/buf-kcat/internal/bsr"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protodesc"
 	"google.golang.org/protobuf/reflect/protoreflect"
@@ -19,14 +25,65 @@ import (
 type Decoder struct {
 	messageTypes map[string]protoreflect.MessageType
 	registry     *protoregistry.Files
+	types        *protoregistry.Types
 	defaultType  string
+	topicTypes   map[string]string
+	schemas      SchemaSource
+	importPaths  []string
+	cacheDir     string
+	strict       bool
+	diagnostics  []Diagnostic
+	jsonOptions  JSONOptions
+	// unknownMessages counts decoded messages with unknown fields.
+	unknownMessages int
+	filter          *filter
+}
+
+// Diagnostic describes a file that could not be loaded from a descriptor set.
+type Diagnostic struct {
+	File string
+	Err  error
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %v", d.File, d.Err)
+}
+
+// Options configures how a Decoder loads its descriptors.
+type Options struct {
+	// ImportPaths are additional directories searched for imports when
+	// .proto files are compiled in-process.
+	ImportPaths []string
+	// CacheDir is where descriptor sets built from sources or downloaded
+	// for a pinned BSR commit are cached. Empty disables the cache.
+	CacheDir string
+	// Strict fails loading if any file cannot be loaded, instead of
+	// skipping it and recording a Diagnostic.
+	Strict bool
+	// JSON controls how decoded messages are rendered.
+	JSON JSONOptions
 }
 
 func NewDecoder(protoPath string, messageType string) (*Decoder, error) {
+	return NewDecoderWithOptions(protoPath, messageType, Options{})
+}
+
+// NewDecoderWithOptions is like NewDecoder but configurable with opts.
+func NewDecoderWithOptions(protoPath string, messageType string, opts Options) (*Decoder, error) {
 	d := &Decoder{
 		messageTypes: make(map[string]protoreflect.MessageType),
 		registry:     new(protoregistry.Files),
+		types:        new(protoregistry.Types),
 		defaultType:  messageType,
+		importPaths:  opts.ImportPaths,
+		cacheDir:     opts.CacheDir,
+		strict:       opts.Strict,
+		jsonOptions:  opts.JSON,
+	}
+
+	// Without a proto path every schema comes from a SchemaSource.
+	if protoPath == "" {
+		return d, nil
 	}
 
 	// Determine input type by file extension and content
@@ -37,12 +94,38 @@ func NewDecoder(protoPath string, messageType string) (*Decoder, error) {
 	return d, nil
 }
 
-// loadProtoDefinitions loads protobuf definitions from either buf.yaml or descriptor set file
+// loadProtoDefinitions loads protobuf definitions from buf.yaml, a descriptor
+// set file, or .proto sources
 func (d *Decoder) loadProtoDefinitions(protoPath string) error {
-	if _, err := os.Stat(protoPath); err != nil {
+	info, err := os.Stat(protoPath)
+	if err != nil {
+		// A path that does not exist locally may name a BSR module.
+		if ref, ok := bsr.ParseModuleRef(protoPath); ok {
+			return d.loadFromBSR(bsr.NewClient(), ref, "buf.lock")
+		}
 		return fmt.Errorf("proto file not found: %w", err)
 	}
 
+	// Directories and .proto files are compiled in-process
+	if info.IsDir() {
+		key, err := d.cacheKey("dir", protoPath)
+		if err != nil {
+			return err
+		}
+		return d.loadBuilt(key, func() ([]byte, error) {
+			return compileSources([]sourceRoot{{dir: protoPath}}, d.importPaths)
+		})
+	}
+	if filepath.Ext(protoPath) == ".proto" {
+		key, err := d.cacheKey("file:"+filepath.Base(protoPath), filepath.Dir(protoPath))
+		if err != nil {
+			return err
+		}
+		return d.loadBuilt(key, func() ([]byte, error) {
+			return compileProtoFile(protoPath, d.importPaths)
+		})
+	}
+
 	// Check if it's a descriptor set file (.desc, .pb, or binary content)
 	if d.isDescriptorSetFile(protoPath) {
 		return d.loadFromDescriptorSet(protoPath)
@@ -95,40 +178,90 @@ func (d *Decoder) loadFromDescriptorSet(descriptorFile string) error {
 	return d.loadDescriptorSet(data)
 }
 
-func (d *Decoder) loadWithBuf(bufYamlPath string) error {
-	if _, err := os.Stat(bufYamlPath); err != nil {
-		return fmt.Errorf("buf.yaml not found: %w", err)
+// loadFromBSR downloads the image of a module from a Buf Schema Registry. A
+// reference without a branch, tag, or commit uses the commit pinned in
+// lockPath if the module is locked there.
+func (d *Decoder) loadFromBSR(client *bsr.Client, ref bsr.ModuleRef, lockPath string) error {
+	if ref.Reference == "" {
+		commit, ok, err := bsr.PinnedReference(lockPath, ref)
+		if err != nil {
+			return err
+		}
+		if ok {
+			ref.Reference = commit
+		}
+	}
+
+	// Only commits are immutable; branches and tags are fetched every time.
+	var key string
+	if bsr.IsCommit(ref.Reference) {
+		sum := sha256.Sum256([]byte(cacheVersion + "\x00bsr\x00" + ref.String()))
+		key = hex.EncodeToString(sum[:])
 	}
+	return d.loadBuilt(key, func() ([]byte, error) {
+		return client.Image(context.Background(), ref)
+	})
+}
 
-	tempDir := filepath.Join(os.TempDir(), "buf-kcat-descriptors")
-	if err := os.MkdirAll(tempDir, 0755); err != nil {
-		return fmt.Errorf("failed to create temp directory: %w", err)
+// cacheKey returns the cache key of the sources in dir, or "" when caching
+// is disabled.
+func (d *Decoder) cacheKey(kind string, dir string) (string, error) {
+	if d.cacheDir == "" {
+		return "", nil
 	}
-	defer os.RemoveAll(tempDir)
+	return sourceKey(kind, []string{dir}, d.importPaths)
+}
 
-	descriptorFile := filepath.Join(tempDir, "image.bin")
+func (d *Decoder) loadWithBuf(bufYamlPath string) error {
+	if _, err := os.Stat(bufYamlPath); err != nil {
+		return fmt.Errorf("buf.yaml not found: %w", err)
+	}
 
 	// Use the directory containing buf.yaml
 	bufDir := filepath.Dir(bufYamlPath)
 
-	// Use buf to build the image
-	cmd := exec.Command("buf", "build", "-o", descriptorFile)
+	// Compile in-process when the buf CLI is not installed, or when import
+	// paths are given, which buf build has no equivalent for. Dependencies
+	// from the BSR must then be available on an import path.
+	if _, err := exec.LookPath("buf"); err != nil || len(d.importPaths) > 0 {
+		key, err := d.cacheKey("buf.yaml", bufDir)
+		if err != nil {
+			return err
+		}
+		return d.loadBuilt(key, func() ([]byte, error) {
+			roots, err := bufModuleRoots(bufYamlPath)
+			if err != nil {
+				return nil, err
+			}
+			return compileSources(roots, d.importPaths)
+		})
+	}
+
+	key, err := d.cacheKey("buf build", bufDir)
+	if err != nil {
+		return err
+	}
+	return d.loadBuilt(key, func() ([]byte, error) {
+		return buildWithBuf(bufDir)
+	})
+}
+
+// buildWithBuf runs buf build in bufDir and returns the image. The image is
+// streamed over stdout rather than written to a file, so concurrent runs
+// never share any temporary state.
+func buildWithBuf(bufDir string) ([]byte, error) {
+	cmd := exec.Command("buf", "build", "-o", "-")
 	cmd.Dir = bufDir
 
-	var stderr bytes.Buffer
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("buf build failed: %v - %s", err, stderr.String())
-	}
-
-	// Read and load the descriptor
-	data, err := os.ReadFile(descriptorFile)
-	if err != nil {
-		return fmt.Errorf("failed to read descriptor: %w", err)
+		return nil, fmt.Errorf("buf build failed: %v - %s", err, stderr.String())
 	}
 
-	return d.loadDescriptorSet(data)
+	return stdout.Bytes(), nil
 }
 
 func (d *Decoder) loadDescriptorSet(data []byte) error {
@@ -142,50 +275,152 @@ func (d *Decoder) loadDescriptorSet(data []byte) error {
 		fdSet.File = []*descriptorpb.FileDescriptorProto{&fdProto}
 	}
 
-	for _, fdProto := range fdSet.File {
-		fd, err := protodesc.NewFile(fdProto, d.registry)
+	failed := make(map[string]bool)
+	for _, fdProto := range sortFilesByDependencies(fdSet.File) {
+		fd, err := d.newFile(fdProto, failed)
 		if err != nil {
-			// Try without registry
-			fd, err = protodesc.NewFile(fdProto, nil)
-			if err != nil {
-				continue
-			}
+			failed[fdProto.GetName()] = true
+			d.diagnostics = append(d.diagnostics, Diagnostic{File: fdProto.GetName(), Err: err})
+			continue
 		}
 
-		// Register the file
-		if _, err := d.registry.FindFileByPath(fd.Path()); err != nil {
-			if err := d.registry.RegisterFile(fd); err != nil {
-				return fmt.Errorf("failed to register file %s: %w", fd.Path(), err)
-			}
+		// Files already loaded, e.g. shared imports, are not registered twice
+		if _, err := d.registry.FindFileByPath(fd.Path()); err == nil {
+			continue
+		}
+		if err := d.registry.RegisterFile(fd); err != nil {
+			return fmt.Errorf("failed to register file %s: %w", fd.Path(), err)
 		}
 
-		// Load all messages
-		d.loadMessages(fd)
+		// Load all messages, enums and extensions
+		if err := d.registerTypes(fd); err != nil {
+			return fmt.Errorf("failed to register types of %s: %w", fd.Path(), err)
+		}
 	}
 
+	if d.strict && len(d.diagnostics) > 0 {
+		return fmt.Errorf("failed to load %d files:\n%s", len(d.diagnostics), formatDiagnostics(d.diagnostics))
+	}
 	if len(d.messageTypes) == 0 {
+		if len(d.diagnostics) > 0 {
+			return fmt.Errorf("no message types loaded; %d files failed:\n%s", len(d.diagnostics), formatDiagnostics(d.diagnostics))
+		}
 		return fmt.Errorf("no message types loaded")
 	}
 
 	return nil
 }
 
-func (d *Decoder) loadMessages(fd protoreflect.FileDescriptor) {
+// newFile builds the descriptor of fdProto, explaining a failure by the
+// imports that are missing or failed to load themselves.
+func (d *Decoder) newFile(fdProto *descriptorpb.FileDescriptorProto, failed map[string]bool) (protoreflect.FileDescriptor, error) {
+	var missing, broken []string
+	for _, dep := range fdProto.GetDependency() {
+		if failed[dep] {
+			broken = append(broken, dep)
+		} else if _, err := d.registry.FindFileByPath(dep); err != nil {
+			missing = append(missing, dep)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing imports: %s", strings.Join(missing, ", "))
+	}
+	if len(broken) > 0 {
+		return nil, fmt.Errorf("imports files that failed to load: %s", strings.Join(broken, ", "))
+	}
+	return protodesc.NewFile(fdProto, d.registry)
+}
+
+// sortFilesByDependencies orders files so that every file comes after the
+// files it imports. Descriptor sets are usually in this order already, but
+// hand-assembled ones need not be.
+func sortFilesByDependencies(files []*descriptorpb.FileDescriptorProto) []*descriptorpb.FileDescriptorProto {
+	byName := make(map[string]*descriptorpb.FileDescriptorProto, len(files))
+	for _, f := range files {
+		byName[f.GetName()] = f
+	}
+
+	sorted := make([]*descriptorpb.FileDescriptorProto, 0, len(files))
+	visited := make(map[string]bool, len(files))
+	var visit func(f *descriptorpb.FileDescriptorProto)
+	visit = func(f *descriptorpb.FileDescriptorProto) {
+		if visited[f.GetName()] {
+			return
+		}
+		// Marking before visiting dependencies ends import cycles, which
+		// then fail to load with a missing import.
+		visited[f.GetName()] = true
+		for _, dep := range f.GetDependency() {
+			if depFile, ok := byName[dep]; ok {
+				visit(depFile)
+			}
+		}
+		sorted = append(sorted, f)
+	}
+	for _, f := range files {
+		visit(f)
+	}
+	return sorted
+}
+
+// registerTypes adds the messages, enums and extensions declared in fd to
+// the decoder's type registry, which resolves extensions and Any payloads.
+func (d *Decoder) registerTypes(fd protoreflect.FileDescriptor) error {
+	if err := d.registerEnums(fd.Enums()); err != nil {
+		return err
+	}
+	if err := d.registerExtensions(fd.Extensions()); err != nil {
+		return err
+	}
 	messages := fd.Messages()
 	for i := 0; i < messages.Len(); i++ {
-		d.loadMessage(messages.Get(i))
+		if err := d.registerMessage(messages.Get(i)); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-func (d *Decoder) loadMessage(msg protoreflect.MessageDescriptor) {
-	fullName := string(msg.FullName())
-	d.messageTypes[fullName] = dynamicpb.NewMessageType(msg)
+func (d *Decoder) registerMessage(msg protoreflect.MessageDescriptor) error {
+	msgType := dynamicpb.NewMessageType(msg)
+	if err := d.types.RegisterMessage(msgType); err != nil {
+		return err
+	}
+	d.messageTypes[string(msg.FullName())] = msgType
+
+	if err := d.registerEnums(msg.Enums()); err != nil {
+		return err
+	}
+	if err := d.registerExtensions(msg.Extensions()); err != nil {
+		return err
+	}
 
 	// Load nested messages
 	nested := msg.Messages()
 	for i := 0; i < nested.Len(); i++ {
-		d.loadMessage(nested.Get(i))
+		if err := d.registerMessage(nested.Get(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Decoder) registerEnums(enums protoreflect.EnumDescriptors) error {
+	for i := 0; i < enums.Len(); i++ {
+		if err := d.types.RegisterEnum(dynamicpb.NewEnumType(enums.Get(i))); err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+func (d *Decoder) registerExtensions(extensions protoreflect.ExtensionDescriptors) error {
+	for i := 0; i < extensions.Len(); i++ {
+		if err := d.types.RegisterExtension(dynamicpb.NewExtensionType(extensions.Get(i))); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (d *Decoder) Decode(data []byte) ([]byte, string, error) {
@@ -197,33 +432,178 @@ func (d *Decoder) Decode(data []byte) ([]byte, string, error) {
 	return d.decodeWithType(data, d.defaultType)
 }
 
-func (d *Decoder) decodeWithType(data []byte, typeName string) ([]byte, string, error) {
+// DecodeType decodes data as the named message type.
+func (d *Decoder) DecodeType(data []byte, typeName string) ([]byte, string, error) {
+	return d.decodeWithType(data, typeName)
+}
+
+// DecodeEmbedded decodes a message carried alongside a record's value, such
+// as in its key or a header, as the named message type. Unlike DecodeType it
+// ignores the filter and does not count towards UnknownMessageCount.
+func (d *Decoder) DecodeEmbedded(data []byte, typeName string) ([]byte, error) {
 	msgType, ok := d.messageTypes[typeName]
 	if !ok {
-		return nil, "", fmt.Errorf("unknown message type: %s", typeName)
+		return nil, d.unknownTypeError(typeName)
 	}
+	return d.marshalPayload(data, msgType, false)
+}
 
-	msg := msgType.New().Interface()
-	if err := proto.Unmarshal(data, msg); err != nil {
-		return nil, "", fmt.Errorf("failed to unmarshal: %w", err)
+// DecodeTopic decodes data using the message type mapped to topic, falling
+// back to the default message type.
+func (d *Decoder) DecodeTopic(topic string, data []byte) ([]byte, string, error) {
+	typeName := d.TypeForTopic(topic)
+	if typeName == "" {
+		return nil, "", fmt.Errorf("no message type for topic %s", topic)
 	}
 
-	// Convert to JSON
-	marshaler := protojson.MarshalOptions{
-		Multiline:       true,
-		Indent:          "  ",
-		EmitUnpopulated: false,
-		UseProtoNames:   true,
+	return d.decodeWithType(data, typeName)
+}
+
+// SetTopicTypes sets the per-topic message type mapping. Keys are topic names
+// or path.Match patterns such as "events.*"; every mapped type must be loaded.
+func (d *Decoder) SetTopicTypes(topicTypes map[string]string) error {
+	for pattern, typeName := range topicTypes {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid topic pattern %q: %w", pattern, err)
+		}
+		if _, ok := d.messageTypes[typeName]; !ok && typeName != AutoType {
+			return fmt.Errorf("topic %s: %w", pattern, d.unknownTypeError(typeName))
+		}
 	}
+	d.topicTypes = topicTypes
+	return nil
+}
 
-	jsonData, err := marshaler.Marshal(msg)
+// CheckType reports an error if typeName is not a loaded message type.
+func (d *Decoder) CheckType(typeName string) error {
+	if _, ok := d.messageTypes[typeName]; !ok {
+		return d.unknownTypeError(typeName)
+	}
+	return nil
+}
+
+// TypeForTopic returns the message type used for records of topic. An exact
+// topic mapping wins over a pattern; without either the default type is used.
+func (d *Decoder) TypeForTopic(topic string) string {
+	if typeName, ok := d.topicTypes[topic]; ok {
+		return typeName
+	}
+
+	// Check patterns in a fixed order so that overlapping patterns resolve
+	// the same way on every run.
+	patterns := make([]string, 0, len(d.topicTypes))
+	for pattern := range d.topicTypes {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, topic); ok {
+			return d.topicTypes[pattern]
+		}
+	}
+
+	return d.defaultType
+}
+
+func (d *Decoder) decodeWithType(data []byte, typeName string) ([]byte, string, error) {
+	if typeName == AutoType {
+		jsonData, inferred, _, err := d.DecodeAuto(data)
+		return jsonData, inferred, err
+	}
+
+	msgType, ok := d.messageTypes[typeName]
+	if !ok {
+		return nil, "", d.unknownTypeError(typeName)
+	}
+
+	jsonData, err := d.marshalPayload(data, msgType, true)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to marshal to JSON: %w", err)
+		return nil, "", err
 	}
 
 	return jsonData, typeName, nil
 }
 
+// marshalPayload unmarshals data as msgType and renders it as JSON with the
+// decoder's JSONOptions. Both steps resolve extensions and Any payloads with
+// the loaded types. Record values are matched against the filter, yielding
+// ErrFiltered when they do not match, and counted by UnknownMessageCount;
+// messages embedded in keys or headers are not.
+func (d *Decoder) marshalPayload(data []byte, msgType protoreflect.MessageType, value bool) ([]byte, error) {
+	msg := msgType.New().Interface()
+	if err := (proto.UnmarshalOptions{Resolver: d.types}).Unmarshal(data, msg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal: %w", err)
+	}
+	if value && d.filter != nil {
+		matched, err := d.filter.match(msg.ProtoReflect())
+		if err != nil {
+			return nil, err
+		}
+		if !matched {
+			return nil, ErrFiltered
+		}
+	}
+
+	jsonData, err := d.marshalOptions().Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal to JSON: %w", err)
+	}
+	if d.jsonOptions.Int64AsNumbers {
+		jsonData, err = d.int64sAsNumbers(jsonData, msgType.Descriptor())
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert 64-bit integers: %w", err)
+		}
+	}
+	if unknown := d.UnknownFields(msg.ProtoReflect()); len(unknown) > 0 {
+		if value {
+			d.unknownMessages++
+		}
+		if d.jsonOptions.ShowUnknown {
+			jsonData, err = addUnknown(jsonData, unknown)
+			if err != nil {
+				return nil, fmt.Errorf("failed to add unknown fields: %w", err)
+			}
+		}
+	}
+
+	return jsonData, nil
+}
+
+// Diagnostics returns the files that were skipped because they could not be
+// loaded.
+func (d *Decoder) Diagnostics() []Diagnostic {
+	return d.diagnostics
+}
+
+// UnknownMessageCount returns how many decoded record values contained
+// fields that their type does not declare. Messages decoded with
+// DecodeEmbedded are not counted.
+func (d *Decoder) UnknownMessageCount() int {
+	return d.unknownMessages
+}
+
+// FileCount returns the number of loaded files.
+func (d *Decoder) FileCount() int {
+	return d.registry.NumFiles()
+}
+
+func formatDiagnostics(diagnostics []Diagnostic) string {
+	lines := make([]string, len(diagnostics))
+	for i, diag := range diagnostics {
+		lines[i] = "  " + diag.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// unknownTypeError reports a message type that is not loaded, pointing at
+// skipped files that may have declared it.
+func (d *Decoder) unknownTypeError(typeName string) error {
+	if len(d.diagnostics) > 0 {
+		return fmt.Errorf("unknown message type: %s (%d files failed to load; run 'buf-kcat list --diagnostics')", typeName, len(d.diagnostics))
+	}
+	return fmt.Errorf("unknown message type: %s", typeName)
+}
+
 func (d *Decoder) MessageTypeCount() int {
 	return len(d.messageTypes)
 }
@@ -236,6 +616,47 @@ func (d *Decoder) MessageTypes() []string {
 	return types
 }
 
+// EnumTypes returns the full names of the loaded enums.
+func (d *Decoder) EnumTypes() []string {
+	var names []string
+	d.types.RangeEnums(func(et protoreflect.EnumType) bool {
+		names = append(names, string(et.Descriptor().FullName()))
+		return true
+	})
+	return names
+}
+
+// Extensions returns the full names of the loaded extensions, keyed by the
+// full name of the message each one extends.
+func (d *Decoder) Extensions() map[string][]string {
+	extensions := make(map[string][]string)
+	d.types.RangeExtensions(func(xt protoreflect.ExtensionType) bool {
+		xd := xt.TypeDescriptor()
+		extendee := string(xd.ContainingMessage().FullName())
+		extensions[extendee] = append(extensions[extendee], string(xd.FullName()))
+		return true
+	})
+	return extensions
+}
+
+// Services returns the full names of the loaded services.
+func (d *Decoder) Services() []string {
+	var names []string
+	d.registry.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		services := fd.Services()
+		for i := 0; i < services.Len(); i++ {
+			names = append(names, string(services.Get(i).FullName()))
+		}
+		return true
+	})
+	return names
+}
+
+// Types returns the registry of loaded messages, enums and extensions.
+func (d *Decoder) Types() *protoregistry.Types {
+	return d.types
+}
+
 // GetMessageTypes returns the map of message types for encoding
 func (d *Decoder) GetMessageTypes() map[string]protoreflect.MessageType {
 	return d.messageTypes
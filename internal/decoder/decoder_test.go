@@ -1,7 +1,25 @@
 package decoder
 
 import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+
+	"github.com/HurSungYun/buf-kcat/internal/bsr"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
 )
 
 func TestDecoder(t *testing.T) {
@@ -14,3 +32,377 @@ func TestDecoder(t *testing.T) {
 		t.Log("Decoder package compiles successfully")
 	})
 }
+
+const testDescriptorSet = "../../test/example/schema.desc"
+
+func TestTypeForTopic(t *testing.T) {
+	dec, err := NewDecoder(testDescriptorSet, "events.UserEvent")
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	if err := dec.SetTopicTypes(map[string]string{
+		"orders.v1":   "events.OrderEvent",
+		"system.*":    "events.SystemEvent",
+		"system.logs": "events.ComplexEvent",
+	}); err != nil {
+		t.Fatalf("SetTopicTypes failed: %v", err)
+	}
+
+	tests := []struct {
+		topic string
+		want  string
+	}{
+		{"orders.v1", "events.OrderEvent"},
+		{"system.metrics", "events.SystemEvent"},
+		{"system.logs", "events.ComplexEvent"},
+		{"users", "events.UserEvent"},
+	}
+	for _, tt := range tests {
+		if got := dec.TypeForTopic(tt.topic); got != tt.want {
+			t.Errorf("TypeForTopic(%q) = %q, want %q", tt.topic, got, tt.want)
+		}
+	}
+
+	if err := dec.SetTopicTypes(map[string]string{"orders": "events.Missing"}); err == nil {
+		t.Error("SetTopicTypes accepted an unknown message type")
+	}
+	if err := dec.SetTopicTypes(map[string]string{"orders[": "events.OrderEvent"}); err == nil {
+		t.Error("SetTopicTypes accepted an invalid pattern")
+	}
+}
+
+func TestLoadTypeMap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "types.txt")
+	content := "# topic to type\norders.v1 = orders.OrderEvent\n\nusers=events.UserEvent\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := LoadTypeMap(path)
+	if err != nil {
+		t.Fatalf("LoadTypeMap failed: %v", err)
+	}
+	want := map[string]string{"orders.v1": "orders.OrderEvent", "users": "events.UserEvent"}
+	if len(got) != len(want) {
+		t.Fatalf("LoadTypeMap = %v, want %v", got, want)
+	}
+	for topic, typeName := range want {
+		if got[topic] != typeName {
+			t.Errorf("LoadTypeMap[%q] = %q, want %q", topic, got[topic], typeName)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("orders.v1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTypeMap(path); err == nil {
+		t.Error("LoadTypeMap accepted a line without '='")
+	}
+}
+
+func TestParseTypeHeader(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"events.OrderEvent", "events.OrderEvent"},
+		{"  events.OrderEvent  ", "events.OrderEvent"},
+		{"type.googleapis.com/events.OrderEvent", "events.OrderEvent"},
+		{"application/x-protobuf; messageType=events.OrderEvent", "events.OrderEvent"},
+		{"application/x-protobuf; charset=utf-8; MessageType=\"events.OrderEvent\"", "events.OrderEvent"},
+		{"application/vnd.google.protobuf; proto=events.OrderEvent", "events.OrderEvent"},
+		{"application/x-protobuf; charset=utf-8", ""},
+		{"application/x-protobuf", ""},
+		{"application/json", ""},
+		{"application/vnd.google.protobuf", ""},
+		{"example.com/schemas/events.OrderEvent", "events.OrderEvent"},
+		{"type.googleapis.com/OrderEvent", ""},
+		{"not a type", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := ParseTypeHeader(tt.value); got != tt.want {
+			t.Errorf("ParseTypeHeader(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestInfer(t *testing.T) {
+	dec, err := NewDecoder(testDescriptorSet, AutoType)
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+
+	msgType := dec.GetMessageTypes()["events.OrderEvent"]
+	msg := msgType.New()
+	fields := msg.Descriptor().Fields()
+	msg.Set(fields.ByName("order_id"), protoreflect.ValueOfString("order-1"))
+	msg.Set(fields.ByName("user_id"), protoreflect.ValueOfString("user-1"))
+	msg.Set(fields.ByName("status"), protoreflect.ValueOfString("PAID"))
+	msg.Set(fields.ByName("total_amount"), protoreflect.ValueOfFloat64(99.5))
+	msg.Set(fields.ByName("payment_method"), protoreflect.ValueOfEnum(3))
+	data, err := proto.Marshal(msg.Interface())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	candidates := dec.Infer(data)
+	if len(candidates) == 0 {
+		t.Fatal("Infer returned no candidates")
+	}
+	if candidates[0].TypeName != "events.OrderEvent" {
+		t.Errorf("best candidate = %s, want events.OrderEvent (candidates: %+v)", candidates[0].TypeName, candidates)
+	}
+	if candidates[0].UnknownBytes != 0 || candidates[0].InvalidEnums != 0 {
+		t.Errorf("best candidate has unknown bytes or invalid enums: %+v", candidates[0])
+	}
+
+	jsonData, typeName, confidence, err := dec.DecodeAuto(data)
+	if err != nil {
+		t.Fatalf("DecodeAuto failed: %v", err)
+	}
+	if typeName != "events.OrderEvent" {
+		t.Errorf("DecodeAuto type = %s, want events.OrderEvent", typeName)
+	}
+	if confidence <= 0 || confidence > 1 {
+		t.Errorf("DecodeAuto confidence = %v, want within (0, 1]", confidence)
+	}
+	if !strings.Contains(string(jsonData), "order-1") {
+		t.Errorf("DecodeAuto JSON missing order_id: %s", jsonData)
+	}
+
+	if _, _, _, err := dec.DecodeAuto(nil); err == nil {
+		t.Error("DecodeAuto accepted an empty payload")
+	}
+}
+
+func TestLoadFromBSR(t *testing.T) {
+	image, err := os.ReadFile(testDescriptorSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var requested string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requested = string(body)
+		var resp []byte
+		resp = protowire.AppendTag(resp, 1, protowire.BytesType)
+		resp = protowire.AppendBytes(resp, image)
+		w.Write(resp)
+	}))
+	defer server.Close()
+
+	remote := strings.TrimPrefix(server.URL, "https://")
+	ref, ok := bsr.ParseModuleRef(remote + "/acme/events")
+	if !ok {
+		t.Fatalf("ParseModuleRef rejected %s/acme/events", remote)
+	}
+	lockPath := filepath.Join(t.TempDir(), "buf.lock")
+	lock := "version: v2\ndeps:\n  - name: " + remote + "/acme/events\n    commit: 0123abcd\n"
+	if err := os.WriteFile(lockPath, []byte(lock), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := &Decoder{
+		messageTypes: make(map[string]protoreflect.MessageType),
+		registry:     new(protoregistry.Files),
+		types:        new(protoregistry.Types),
+	}
+	if err := d.loadFromBSR(&bsr.Client{HTTPClient: server.Client()}, ref, lockPath); err != nil {
+		t.Fatalf("loadFromBSR failed: %v", err)
+	}
+	if _, ok := d.messageTypes["events.OrderEvent"]; !ok {
+		t.Error("image from the registry did not load events.OrderEvent")
+	}
+	if !strings.Contains(requested, "0123abcd") {
+		t.Errorf("request %q does not use the commit pinned in buf.lock", requested)
+	}
+}
+
+func TestParallelDecoders(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake buf CLI is a shell script")
+	}
+	image, err := filepath.Abs(testDescriptorSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A fake buf CLI that is slow enough for the builds to overlap.
+	binDir := t.TempDir()
+	script := "#!/bin/sh\n" +
+		"[ \"$*\" = \"build -o -\" ] || { echo \"unexpected arguments: $*\" >&2; exit 1; }\n" +
+		"sleep 0.2\n" +
+		"cat '" + image + "'\n"
+	if err := os.WriteFile(filepath.Join(binDir, "buf"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	const workers = 8
+	cacheDir := t.TempDir()
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*workers)
+	for i := 0; i < workers; i++ {
+		for _, opts := range []Options{{}, {CacheDir: cacheDir}} {
+			wg.Add(1)
+			go func(opts Options) {
+				defer wg.Done()
+				dec, err := NewDecoderWithOptions("../../test/example/buf.yaml", "events.OrderEvent", opts)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if _, _, err := dec.DecodeType([]byte{0x0a, 0x01, 'x'}, "events.OrderEvent"); err != nil {
+					errs <- err
+				}
+			}(opts)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("parallel decoder failed: %v", err)
+	}
+}
+
+func TestLoadDiagnostics(t *testing.T) {
+	file := func(name, pkg string, deps ...string) *descriptorpb.FileDescriptorProto {
+		return &descriptorpb.FileDescriptorProto{
+			Name:        proto.String(name),
+			Package:     proto.String(pkg),
+			Syntax:      proto.String("proto3"),
+			Dependency:  deps,
+			MessageType: []*descriptorpb.DescriptorProto{{Name: proto.String("Message")}},
+		}
+	}
+	// Importers come before their imports, and one import is missing.
+	fdSet := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{
+		file("app.proto", "app", "common.proto"),
+		file("common.proto", "common"),
+		file("billing.proto", "billing", "money.proto"),
+		file("invoice.proto", "invoice", "billing.proto"),
+	}}
+	data, err := proto.Marshal(fdSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "set.desc")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dec, err := NewDecoder(path, "")
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	for _, typeName := range []string{"app.Message", "common.Message"} {
+		if _, ok := dec.GetMessageTypes()[typeName]; !ok {
+			t.Errorf("%s was not loaded despite its file order", typeName)
+		}
+	}
+	if dec.FileCount() != 2 {
+		t.Errorf("FileCount = %d, want 2", dec.FileCount())
+	}
+
+	diagnostics := dec.Diagnostics()
+	if len(diagnostics) != 2 {
+		t.Fatalf("Diagnostics = %v, want billing.proto and invoice.proto", diagnostics)
+	}
+	if diagnostics[0].File != "billing.proto" || !strings.Contains(diagnostics[0].Err.Error(), "missing imports: money.proto") {
+		t.Errorf("diagnostic = %s, want billing.proto missing money.proto", diagnostics[0])
+	}
+	if diagnostics[1].File != "invoice.proto" || !strings.Contains(diagnostics[1].Err.Error(), "billing.proto") {
+		t.Errorf("diagnostic = %s, want invoice.proto failing through billing.proto", diagnostics[1])
+	}
+
+	_, _, err = dec.DecodeType(nil, "billing.Message")
+	if err == nil || !strings.Contains(err.Error(), "2 files failed to load") {
+		t.Errorf("DecodeType of a skipped type error = %v, want it to point at the diagnostics", err)
+	}
+
+	_, err = NewDecoderWithOptions(path, "", Options{Strict: true})
+	if err == nil || !strings.Contains(err.Error(), "billing.proto: missing imports: money.proto") {
+		t.Errorf("strict NewDecoderWithOptions error = %v, want it to list the failed files", err)
+	}
+}
+
+func TestRegisterTypes(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"shop.proto": `syntax = "proto2";
+package shop;
+
+message Item {
+  optional string name = 1;
+  extensions 100 to 199;
+}
+
+extend Item {
+  optional string note = 100;
+}
+
+message Labels {
+  extend Item {
+    optional int32 rank = 101;
+  }
+  enum Color {
+    RED = 0;
+  }
+}
+
+enum Size {
+  SMALL = 0;
+  LARGE = 1;
+}
+
+service Catalog {
+  rpc Get(Item) returns (Item);
+}
+`,
+	})
+
+	dec, err := NewDecoder(dir, "")
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+
+	enums := dec.EnumTypes()
+	sort.Strings(enums)
+	if want := []string{"shop.Labels.Color", "shop.Size"}; !reflect.DeepEqual(enums, want) {
+		t.Errorf("EnumTypes = %v, want %v", enums, want)
+	}
+	if services := dec.Services(); !reflect.DeepEqual(services, []string{"shop.Catalog"}) {
+		t.Errorf("Services = %v, want [shop.Catalog]", services)
+	}
+	extensions := dec.Extensions()["shop.Item"]
+	sort.Strings(extensions)
+	if want := []string{"shop.Labels.rank", "shop.note"}; !reflect.DeepEqual(extensions, want) {
+		t.Errorf("Extensions of shop.Item = %v, want %v", extensions, want)
+	}
+
+	var payload []byte
+	payload = protowire.AppendTag(payload, 1, protowire.BytesType)
+	payload = protowire.AppendString(payload, "lamp")
+	payload = protowire.AppendTag(payload, 100, protowire.BytesType)
+	payload = protowire.AppendString(payload, "fragile")
+	payload = protowire.AppendTag(payload, 101, protowire.VarintType)
+	payload = protowire.AppendVarint(payload, 3)
+
+	jsonData, _, err := dec.DecodeType(payload, "shop.Item")
+	if err != nil {
+		t.Fatalf("DecodeType failed: %v", err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(jsonData, &got); err != nil {
+		t.Fatalf("invalid JSON %s: %v", jsonData, err)
+	}
+	want := map[string]any{
+		"name":               "lamp",
+		"[shop.note]":        "fragile",
+		"[shop.Labels.rank]": float64(3),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DecodeType = %v, want %v", got, want)
+	}
+}
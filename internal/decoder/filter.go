@@ -0,0 +1,103 @@
+package decoder
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ErrFiltered is returned for messages that do not match the filter set with
+// SetFilter.
+var ErrFiltered = errors.New("message does not match the filter")
+
+// filter is a CEL expression over the fields of a decoded message, such as
+// `user_id == "42" && total_amount > 100.0`. The expression is compiled
+// separately for every message type it is applied to.
+type filter struct {
+	expr     string
+	programs map[protoreflect.MessageDescriptor]compiledFilter
+}
+
+type compiledFilter struct {
+	program cel.Program
+	err     error
+}
+
+// SetFilter makes the decoder render only values that match the CEL
+// expression expr, in which the fields of the message are variables. The
+// expression is type-checked against the default and per-topic message
+// types; types only known when a record arrives, such as inferred types or
+// registry schemas, are checked on first use. Messages decoded with
+// DecodeEmbedded are never filtered.
+func (d *Decoder) SetFilter(expr string) error {
+	f := &filter{
+		expr:     expr,
+		programs: make(map[protoreflect.MessageDescriptor]compiledFilter),
+	}
+
+	typeNames := []string{d.defaultType}
+	for _, typeName := range d.topicTypes {
+		typeNames = append(typeNames, typeName)
+	}
+	for _, typeName := range typeNames {
+		msgType, ok := d.messageTypes[typeName]
+		if !ok {
+			continue
+		}
+		if _, err := f.program(msgType.Descriptor()); err != nil {
+			return err
+		}
+	}
+
+	d.filter = f
+	return nil
+}
+
+// program returns the expression compiled for messages of type md.
+func (f *filter) program(md protoreflect.MessageDescriptor) (cel.Program, error) {
+	if compiled, ok := f.programs[md]; ok {
+		return compiled.program, compiled.err
+	}
+	program, err := f.compile(md)
+	f.programs[md] = compiledFilter{program: program, err: err}
+	return program, err
+}
+
+func (f *filter) compile(md protoreflect.MessageDescriptor) (cel.Program, error) {
+	env, err := cel.NewEnv(cel.TypeDescs(md.ParentFile()), cel.DeclareContextProto(md))
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare filter for %s: %w", md.FullName(), err)
+	}
+	ast, issues := env.Compile(f.expr)
+	if issues.Err() != nil {
+		return nil, fmt.Errorf("invalid filter for %s: %w", md.FullName(), issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, fmt.Errorf("invalid filter for %s: expression is %s, not bool", md.FullName(), ast.OutputType())
+	}
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter for %s: %w", md.FullName(), err)
+	}
+	return program, nil
+}
+
+// match evaluates the filter against msg.
+func (f *filter) match(msg protoreflect.Message) (bool, error) {
+	program, err := f.program(msg.Descriptor())
+	if err != nil {
+		return false, err
+	}
+	vars, err := cel.ContextProtoVars(msg.Interface())
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate filter: %w", err)
+	}
+	out, _, err := program.Eval(vars)
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate filter: %w", err)
+	}
+	matched, _ := out.Value().(bool)
+	return matched, nil
+}
@@ -0,0 +1,87 @@
+package decoder
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestFilter(t *testing.T) {
+	dec, err := NewDecoder(testDescriptorSet, "events.OrderEvent")
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+
+	order := func(userID string, total float64, quantity uint64) []byte {
+		var item []byte
+		item = protowire.AppendTag(item, 1, protowire.BytesType)
+		item = protowire.AppendString(item, "p-1")
+		item = protowire.AppendTag(item, 2, protowire.VarintType)
+		item = protowire.AppendVarint(item, quantity)
+
+		var b []byte
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendString(b, userID)
+		b = protowire.AppendTag(b, 4, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(total))
+		b = protowire.AppendTag(b, 5, protowire.BytesType)
+		b = protowire.AppendBytes(b, item)
+		return b
+	}
+
+	if err := dec.SetFilter(`user_id == "42" && total_amount > 100.0 && items.exists(i, i.quantity >= 2)`); err != nil {
+		t.Fatalf("SetFilter failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		payload []byte
+		match   bool
+	}{
+		{"match", order("42", 150, 2), true},
+		{"other user", order("7", 150, 2), false},
+		{"small total", order("42", 99.5, 2), false},
+		{"single items", order("42", 150, 1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jsonData, _, err := dec.Decode(tt.payload)
+			if tt.match {
+				if err != nil || !strings.Contains(string(jsonData), `"42"`) {
+					t.Errorf("Decode = %s, %v, want the matching message", jsonData, err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrFiltered) {
+				t.Errorf("Decode error = %v, want ErrFiltered", err)
+			}
+		})
+	}
+
+	// Keys and headers are decoded regardless of the filter.
+	if _, err := dec.DecodeEmbedded(order("7", 1, 1), "events.OrderEvent"); err != nil {
+		t.Errorf("DecodeEmbedded failed: %v", err)
+	}
+
+	errTests := []struct {
+		name    string
+		expr    string
+		wantErr string
+	}{
+		{"unknown field", `customer_id == "42"`, "undeclared reference to 'customer_id'"},
+		{"type mismatch", `total_amount > "100"`, "no matching overload"},
+		{"not bool", `total_amount * 2.0`, "not bool"},
+		{"syntax", `user_id ==`, "invalid filter for events.OrderEvent"},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := dec.SetFilter(tt.expr)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("SetFilter(%q) error = %v, want it to contain %q", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
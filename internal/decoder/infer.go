@@ -0,0 +1,156 @@
+package decoder
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// AutoType is the message type name that asks the decoder to infer the type
+// of each payload from the loaded descriptors.
+const AutoType = "auto"
+
+// Candidate is a message type that a payload could be decoded as.
+type Candidate struct {
+	TypeName string
+	// Score rates how well the type explains the payload, from 0 to 1.
+	Score float64
+
+	UnknownBytes   int
+	InvalidEnums   int
+	InvalidStrings int
+	FieldsSet      int
+	FieldsDeclared int
+}
+
+// Infer decodes data as every loaded message type and returns the types that
+// could plausibly have produced it, best match first.
+//
+// A candidate is scored by the share of the payload it explains without
+// unknown fields, halved for every enum value it does not declare and every
+// string that is not valid UTF-8, and weighted by how many of its top-level
+// fields the payload populates.
+func (d *Decoder) Infer(data []byte) []Candidate {
+	var candidates []Candidate
+	for name, msgType := range d.messageTypes {
+		desc := msgType.Descriptor()
+		if desc.IsMapEntry() {
+			continue
+		}
+
+		msg := msgType.New().Interface()
+		if err := (proto.UnmarshalOptions{Resolver: d.types}).Unmarshal(data, msg); err != nil {
+			continue
+		}
+
+		c := Candidate{
+			TypeName:       name,
+			FieldsDeclared: desc.Fields().Len(),
+		}
+		msg.ProtoReflect().Range(func(protoreflect.FieldDescriptor, protoreflect.Value) bool {
+			c.FieldsSet++
+			return true
+		})
+		inspectMessage(msg.ProtoReflect(), &c)
+
+		c.Score = scoreCandidate(c, len(data))
+		if c.Score > 0 {
+			candidates = append(candidates, c)
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].TypeName < candidates[j].TypeName
+	})
+	return candidates
+}
+
+// Confidence rates how clearly the first candidate beats the second, from 0
+// to 1. Two equally good candidates yield at most 0.5.
+func Confidence(candidates []Candidate) float64 {
+	if len(candidates) == 0 {
+		return 0
+	}
+	best := candidates[0].Score
+	if len(candidates) == 1 {
+		return best
+	}
+	return best * best / (best + candidates[1].Score)
+}
+
+// DecodeAuto decodes data as the best inferred message type and reports the
+// confidence of the match.
+func (d *Decoder) DecodeAuto(data []byte) ([]byte, string, float64, error) {
+	candidates := d.Infer(data)
+	if len(candidates) == 0 {
+		return nil, "", 0, fmt.Errorf("no loaded message type matches the payload")
+	}
+	jsonData, typeName, err := d.decodeWithType(data, candidates[0].TypeName)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	return jsonData, typeName, Confidence(candidates), nil
+}
+
+func scoreCandidate(c Candidate, size int) float64 {
+	if size == 0 {
+		// Every type decodes an empty payload equally well.
+		return 0
+	}
+	explained := 1 - float64(c.UnknownBytes)/float64(size)
+	if explained <= 0 {
+		return 0
+	}
+	score := explained
+	for i := 0; i < c.InvalidEnums+c.InvalidStrings; i++ {
+		score /= 2
+	}
+	coverage := 0.0
+	if c.FieldsDeclared > 0 {
+		coverage = float64(c.FieldsSet) / float64(c.FieldsDeclared)
+	}
+	return score * (0.5 + 0.5*coverage)
+}
+
+func inspectMessage(m protoreflect.Message, c *Candidate) {
+	c.UnknownBytes += len(m.GetUnknown())
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList():
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				inspectValue(fd, list.Get(i), c)
+			}
+		case fd.IsMap():
+			v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+				inspectValue(fd.MapKey(), k.Value(), c)
+				inspectValue(fd.MapValue(), mv, c)
+				return true
+			})
+		default:
+			inspectValue(fd, v, c)
+		}
+		return true
+	})
+}
+
+func inspectValue(fd protoreflect.FieldDescriptor, v protoreflect.Value, c *Candidate) {
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		if fd.Enum().Values().ByNumber(v.Enum()) == nil {
+			c.InvalidEnums++
+		}
+	case protoreflect.StringKind:
+		if !utf8.ValidString(v.String()) {
+			c.InvalidStrings++
+		}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		inspectMessage(v.Message(), c)
+	}
+}
@@ -0,0 +1,167 @@
+package decoder
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// JSONOptions controls how decoded messages are rendered as JSON. The zero
+// value renders proto field names, omits unpopulated fields, names enum
+// values and quotes 64-bit integers, as protojson does by default.
+type JSONOptions struct {
+	// EmitDefaults renders fields that are not set with their default value.
+	EmitDefaults bool
+	// JSONNames uses the lowerCamelCase JSON names of fields instead of the
+	// names declared in the .proto file.
+	JSONNames bool
+	// EnumsAsInts renders enum values as numbers instead of names.
+	EnumsAsInts bool
+	// Int64AsNumbers renders 64-bit integers as JSON numbers instead of
+	// strings. Values beyond 2^53 may lose precision in JavaScript readers.
+	Int64AsNumbers bool
+	// ShowUnknown adds an "_unknown" object to the rendered message, holding
+	// the fields at any depth that their type does not declare. See
+	// UnknownFields.
+	ShowUnknown bool
+}
+
+// SetJSONOptions changes how subsequently decoded messages are rendered.
+func (d *Decoder) SetJSONOptions(opts JSONOptions) {
+	d.jsonOptions = opts
+}
+
+func (d *Decoder) marshalOptions() protojson.MarshalOptions {
+	return protojson.MarshalOptions{
+		Multiline:       true,
+		Indent:          "  ",
+		EmitUnpopulated: d.jsonOptions.EmitDefaults,
+		UseProtoNames:   !d.jsonOptions.JSONNames,
+		UseEnumNumbers:  d.jsonOptions.EnumsAsInts,
+		Resolver:        d.types,
+	}
+}
+
+// int64sAsNumbers rewrites the 64-bit integers in jsonData, which protojson
+// renders as strings, as JSON numbers. The message descriptor tells which
+// strings hold integers.
+func (d *Decoder) int64sAsNumbers(jsonData []byte, md protoreflect.MessageDescriptor) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(jsonData))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(d.numberMessage(value, md), "", "  ")
+}
+
+func (d *Decoder) numberMessage(v any, md protoreflect.MessageDescriptor) any {
+	switch md.FullName() {
+	case "google.protobuf.Int64Value", "google.protobuf.UInt64Value":
+		return stringToNumber(v)
+	case "google.protobuf.Any":
+		return d.numberAny(v)
+	}
+	// The remaining well-known types have their own JSON forms without
+	// 64-bit integers.
+	if md.ParentFile().Package() == "google.protobuf" {
+		return v
+	}
+
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return v
+	}
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		name := d.fieldName(fd)
+		if fv, ok := obj[name]; ok {
+			obj[name] = d.numberField(fv, fd)
+		}
+	}
+	for name, fv := range obj {
+		if !strings.HasPrefix(name, "[") || !strings.HasSuffix(name, "]") {
+			continue
+		}
+		xt, err := d.types.FindExtensionByName(protoreflect.FullName(name[1 : len(name)-1]))
+		if err == nil {
+			obj[name] = d.numberField(fv, xt.TypeDescriptor())
+		}
+	}
+	return obj
+}
+
+// fieldName returns the key of a field in the rendered JSON.
+func (d *Decoder) fieldName(fd protoreflect.FieldDescriptor) string {
+	if fd.IsExtension() {
+		return "[" + string(fd.FullName()) + "]"
+	}
+	if d.jsonOptions.JSONNames {
+		return fd.JSONName()
+	}
+	return fd.TextName()
+}
+
+// numberAny rewrites the message packed in an Any, whose fields sit next to
+// its @type, or under "value" for well-known types with their own JSON form.
+func (d *Decoder) numberAny(v any) any {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return v
+	}
+	typeURL, _ := obj["@type"].(string)
+	mt, err := d.types.FindMessageByURL(typeURL)
+	if err != nil {
+		return obj
+	}
+	md := mt.Descriptor()
+	if md.ParentFile().Package() == "google.protobuf" {
+		if value, ok := obj["value"]; ok {
+			obj["value"] = d.numberMessage(value, md)
+		}
+		return obj
+	}
+	return d.numberMessage(obj, md)
+}
+
+func (d *Decoder) numberField(v any, fd protoreflect.FieldDescriptor) any {
+	switch {
+	case fd.IsMap():
+		if obj, ok := v.(map[string]any); ok {
+			for k, mv := range obj {
+				obj[k] = d.numberSingular(mv, fd.MapValue())
+			}
+		}
+	case fd.IsList():
+		if list, ok := v.([]any); ok {
+			for i, lv := range list {
+				list[i] = d.numberSingular(lv, fd)
+			}
+		}
+	default:
+		return d.numberSingular(v, fd)
+	}
+	return v
+}
+
+func (d *Decoder) numberSingular(v any, fd protoreflect.FieldDescriptor) any {
+	switch fd.Kind() {
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return stringToNumber(v)
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return d.numberMessage(v, fd.Message())
+	}
+	return v
+}
+
+func stringToNumber(v any) any {
+	if s, ok := v.(string); ok {
+		return json.Number(s)
+	}
+	return v
+}
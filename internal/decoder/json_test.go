@@ -0,0 +1,122 @@
+package decoder
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func TestJSONOptions(t *testing.T) {
+	dec, err := NewDecoder(testDescriptorSet, "")
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+
+	msgTypes := dec.GetMessageTypes()
+	primitives := msgTypes["events.PrimitiveTypesEvent"].New()
+	fields := primitives.Descriptor().Fields()
+	primitives.Set(fields.ByName("int64_field"), protoreflect.ValueOfInt64(-9007199254740993))
+	primitives.Set(fields.ByName("uint64_field"), protoreflect.ValueOfUint64(18446744073709551615))
+	primitives.Set(fields.ByName("string_field"), protoreflect.ValueOfString("s"))
+	primitivesData, err := proto.Marshal(primitives.Interface())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order := msgTypes["events.OrderEvent"].New()
+	order.Set(order.Descriptor().Fields().ByName("payment_method"), protoreflect.ValueOfEnum(3))
+	orderData, err := proto.Marshal(order.Interface())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	custom := msgTypes["events.CustomData"].New()
+	custom.Set(custom.Descriptor().Fields().ByName("data_type"), protoreflect.ValueOfString("primitives"))
+	payload := custom.Mutable(custom.Descriptor().Fields().ByName("payload")).Message()
+	payload.Set(payload.Descriptor().Fields().ByName("type_url"), protoreflect.ValueOfString("type.googleapis.com/events.PrimitiveTypesEvent"))
+	payload.Set(payload.Descriptor().Fields().ByName("value"), protoreflect.ValueOfBytes(primitivesData))
+	customData, err := proto.Marshal(custom.Interface())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		opts     JSONOptions
+		typeName string
+		data     []byte
+		want     string
+	}{
+		{
+			name:     "defaults",
+			typeName: "events.OrderEvent",
+			data:     orderData,
+			want:     `{"payment_method": "PAYPAL"}`,
+		},
+		{
+			name:     "enums as ints",
+			opts:     JSONOptions{EnumsAsInts: true},
+			typeName: "events.OrderEvent",
+			data:     orderData,
+			want:     `{"payment_method": 3}`,
+		},
+		{
+			name:     "emit defaults with JSON names",
+			opts:     JSONOptions{EmitDefaults: true, JSONNames: true},
+			typeName: "events.OrderEvent",
+			data:     orderData,
+			want:     `{"orderId": "", "userId": "", "status": "", "totalAmount": 0, "items": [], "createdAt": null, "paymentMethod": "PAYPAL"}`,
+		},
+		{
+			name:     "int64 as strings",
+			typeName: "events.PrimitiveTypesEvent",
+			data:     primitivesData,
+			want:     `{"string_field": "s", "int64_field": "-9007199254740993", "uint64_field": "18446744073709551615"}`,
+		},
+		{
+			name:     "int64 as numbers",
+			opts:     JSONOptions{Int64AsNumbers: true, JSONNames: true},
+			typeName: "events.PrimitiveTypesEvent",
+			data:     primitivesData,
+			want:     `{"stringField": "s", "int64Field": -9007199254740993, "uint64Field": 18446744073709551615}`,
+		},
+		{
+			name:     "int64 as numbers inside Any",
+			opts:     JSONOptions{Int64AsNumbers: true},
+			typeName: "events.CustomData",
+			data:     customData,
+			want: `{"payload": {"@type": "type.googleapis.com/events.PrimitiveTypesEvent",
+				"string_field": "s", "int64_field": -9007199254740993, "uint64_field": 18446744073709551615},
+				"data_type": "primitives"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec.SetJSONOptions(tt.opts)
+			jsonData, _, err := dec.DecodeType(tt.data, tt.typeName)
+			if err != nil {
+				t.Fatalf("DecodeType failed: %v", err)
+			}
+			// Numbers are compared as written, so that a rounded 64-bit
+			// integer does not pass for the original.
+			if got, want := parseJSON(t, jsonData), parseJSON(t, []byte(tt.want)); !reflect.DeepEqual(got, want) {
+				t.Errorf("DecodeType = %s, want %s", jsonData, tt.want)
+			}
+		})
+	}
+}
+
+func parseJSON(t *testing.T, data []byte) any {
+	t.Helper()
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		t.Fatalf("invalid JSON %s: %v", data, err)
+	}
+	return v
+}
@@ -0,0 +1,197 @@
+package decoder
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// maxRawDepth bounds how deeply DecodeRaw descends into length-delimited
+// fields that look like nested messages.
+const maxRawDepth = 64
+
+// RawField is a field decoded from the protobuf wire format without a
+// schema, as protoc --decode_raw does. Exactly one of the value fields is set.
+type RawField struct {
+	Number   protowire.Number `json:"field"`
+	WireType string           `json:"wire_type"`
+
+	// Value holds varint, fixed32 and fixed64 values.
+	Value *uint64 `json:"value,omitempty"`
+	// Length-delimited values are shown as the first interpretation that
+	// fits: a string, a nested message, packed varints, or raw bytes.
+	Message []RawField `json:"message,omitempty"`
+	String  *string    `json:"string,omitempty"`
+	Packed  []uint64   `json:"packed,omitempty"`
+	Bytes   *string    `json:"bytes_hex,omitempty"`
+	// Group holds the fields of a (deprecated) group.
+	Group []RawField `json:"group,omitempty"`
+}
+
+// DecodeRaw walks the protobuf wire format of data without a descriptor.
+func DecodeRaw(data []byte) ([]RawField, error) {
+	return decodeRawFields(data, 0)
+}
+
+func decodeRawFields(data []byte, depth int) ([]RawField, error) {
+	var fields []RawField
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, fmt.Errorf("invalid tag: %w", protowire.ParseError(n))
+		}
+		data = data[n:]
+
+		field := RawField{Number: num}
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return nil, fmt.Errorf("field %d: invalid varint: %w", num, protowire.ParseError(n))
+			}
+			field.WireType = "varint"
+			field.Value = &v
+			data = data[n:]
+		case protowire.Fixed32Type:
+			v, n := protowire.ConsumeFixed32(data)
+			if n < 0 {
+				return nil, fmt.Errorf("field %d: invalid fixed32: %w", num, protowire.ParseError(n))
+			}
+			v64 := uint64(v)
+			field.WireType = "fixed32"
+			field.Value = &v64
+			data = data[n:]
+		case protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(data)
+			if n < 0 {
+				return nil, fmt.Errorf("field %d: invalid fixed64: %w", num, protowire.ParseError(n))
+			}
+			field.WireType = "fixed64"
+			field.Value = &v
+			data = data[n:]
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return nil, fmt.Errorf("field %d: invalid length-delimited value: %w", num, protowire.ParseError(n))
+			}
+			field.WireType = "bytes"
+			interpretBytes(&field, v, depth)
+			data = data[n:]
+		case protowire.StartGroupType:
+			v, n := protowire.ConsumeGroup(num, data)
+			if n < 0 {
+				return nil, fmt.Errorf("field %d: invalid group: %w", num, protowire.ParseError(n))
+			}
+			if depth >= maxRawDepth {
+				return nil, fmt.Errorf("field %d: groups nested too deeply", num)
+			}
+			group, err := decodeRawFields(v, depth+1)
+			if err != nil {
+				return nil, fmt.Errorf("field %d: %w", num, err)
+			}
+			field.WireType = "group"
+			field.Group = group
+			data = data[n:]
+		default:
+			return nil, fmt.Errorf("field %d: unexpected wire type %d", num, typ)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// interpretBytes picks the most plausible reading of a length-delimited value.
+// Text is tried first: short strings often happen to be valid wire format too,
+// while real nested messages almost always contain unprintable tag bytes.
+func interpretBytes(field *RawField, v []byte, depth int) {
+	if isPrintable(v) {
+		s := string(v)
+		field.String = &s
+		return
+	}
+	if depth < maxRawDepth {
+		if nested, err := decodeRawFields(v, depth+1); err == nil {
+			field.Message = nested
+			return
+		}
+	}
+	if packed, ok := decodePackedVarints(v); ok {
+		field.Packed = packed
+		return
+	}
+	h := hex.EncodeToString(v)
+	field.Bytes = &h
+}
+
+func decodePackedVarints(v []byte) ([]uint64, bool) {
+	var values []uint64
+	for len(v) > 0 {
+		x, n := protowire.ConsumeVarint(v)
+		if n < 0 {
+			return nil, false
+		}
+		values = append(values, x)
+		v = v[n:]
+	}
+	return values, len(values) > 0
+}
+
+func isPrintable(v []byte) bool {
+	if !utf8.Valid(v) {
+		return false
+	}
+	for _, r := range string(v) {
+		if !unicode.IsPrint(r) && r != '\n' && r != '\r' && r != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+// Protoscope renders raw fields in the text syntax of the protoscope tool,
+// e.g. `1: 150` or `2: {"hello"}`.
+func Protoscope(fields []RawField) string {
+	var b strings.Builder
+	writeProtoscope(&b, fields, "")
+	return b.String()
+}
+
+func writeProtoscope(b *strings.Builder, fields []RawField, indent string) {
+	for _, f := range fields {
+		fmt.Fprintf(b, "%s%d: ", indent, f.Number)
+		switch {
+		case f.Value != nil:
+			b.WriteString(strconv.FormatUint(*f.Value, 10))
+			switch f.WireType {
+			case "fixed32":
+				b.WriteString("i32")
+			case "fixed64":
+				b.WriteString("i64")
+			}
+		case f.String != nil:
+			fmt.Fprintf(b, "{%s}", strconv.Quote(*f.String))
+		case f.Packed != nil:
+			values := make([]string, len(f.Packed))
+			for i, v := range f.Packed {
+				values[i] = strconv.FormatUint(v, 10)
+			}
+			fmt.Fprintf(b, "{%s}", strings.Join(values, " "))
+		case f.Bytes != nil:
+			fmt.Fprintf(b, "{`%s`}", *f.Bytes)
+		case f.WireType == "group":
+			b.WriteString("!{\n")
+			writeProtoscope(b, f.Group, indent+"  ")
+			fmt.Fprintf(b, "%s}", indent)
+		default:
+			b.WriteString("{\n")
+			writeProtoscope(b, f.Message, indent+"  ")
+			fmt.Fprintf(b, "%s}", indent)
+		}
+		b.WriteString("\n")
+	}
+}
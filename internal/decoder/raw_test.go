@@ -0,0 +1,87 @@
+package decoder
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestDecodeRaw(t *testing.T) {
+	var nested []byte
+	nested = protowire.AppendTag(nested, 1, protowire.VarintType)
+	nested = protowire.AppendVarint(nested, 42)
+
+	var packed []byte
+	for _, v := range []uint64{1, 2, 300} {
+		packed = protowire.AppendVarint(packed, v)
+	}
+
+	var data []byte
+	data = protowire.AppendTag(data, 1, protowire.VarintType)
+	data = protowire.AppendVarint(data, 150)
+	data = protowire.AppendTag(data, 2, protowire.BytesType)
+	data = protowire.AppendString(data, "hello")
+	data = protowire.AppendTag(data, 3, protowire.BytesType)
+	data = protowire.AppendBytes(data, nested)
+	data = protowire.AppendTag(data, 4, protowire.Fixed64Type)
+	data = protowire.AppendFixed64(data, 7)
+	data = protowire.AppendTag(data, 5, protowire.Fixed32Type)
+	data = protowire.AppendFixed32(data, 9)
+	data = protowire.AppendTag(data, 6, protowire.BytesType)
+	data = protowire.AppendBytes(data, packed)
+	data = protowire.AppendTag(data, 7, protowire.StartGroupType)
+	data = append(data, nested...)
+	data = protowire.AppendTag(data, 7, protowire.EndGroupType)
+
+	fields, err := DecodeRaw(data)
+	if err != nil {
+		t.Fatalf("DecodeRaw failed: %v", err)
+	}
+	if len(fields) != 7 {
+		t.Fatalf("DecodeRaw returned %d fields, want 7: %+v", len(fields), fields)
+	}
+
+	if f := fields[0]; f.WireType != "varint" || f.Value == nil || *f.Value != 150 {
+		t.Errorf("field 1 = %+v, want varint 150", f)
+	}
+	if f := fields[1]; f.String == nil || *f.String != "hello" {
+		t.Errorf("field 2 = %+v, want string hello", f)
+	}
+	if f := fields[2]; len(f.Message) != 1 || *f.Message[0].Value != 42 {
+		t.Errorf("field 3 = %+v, want nested message {1: 42}", f)
+	}
+	if f := fields[3]; f.WireType != "fixed64" || *f.Value != 7 {
+		t.Errorf("field 4 = %+v, want fixed64 7", f)
+	}
+	if f := fields[4]; f.WireType != "fixed32" || *f.Value != 9 {
+		t.Errorf("field 5 = %+v, want fixed32 9", f)
+	}
+	if f := fields[5]; len(f.Packed) != 3 || f.Packed[2] != 300 {
+		t.Errorf("field 6 = %+v, want packed [1 2 300]", f)
+	}
+	if f := fields[6]; f.WireType != "group" || len(f.Group) != 1 {
+		t.Errorf("field 7 = %+v, want group {1: 42}", f)
+	}
+
+	text := Protoscope(fields)
+	for _, want := range []string{"1: 150\n", `2: {"hello"}`, "3: {\n  1: 42\n}", "4: 7i64", "5: 9i32", "6: {1 2 300}", "7: !{\n  1: 42\n}"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Protoscope output missing %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestDecodeRawInvalid(t *testing.T) {
+	tests := map[string][]byte{
+		"truncated varint": {0x08, 0x96},
+		"truncated bytes":  {0x12, 0x05, 'h', 'i'},
+		"zero field":       {0x00, 0x01},
+		"stray end group":  {0x0c},
+	}
+	for name, data := range tests {
+		if _, err := DecodeRaw(data); err == nil {
+			t.Errorf("%s: DecodeRaw(%x) succeeded, want error", name, data)
+		}
+	}
+}
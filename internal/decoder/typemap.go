@@ -0,0 +1,105 @@
+package decoder
+
+import (
+	"bufio"
+	"fmt"
+	"mime"
+	"os"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// LoadTypeMap reads a topic to message type mapping file. Each non-empty line
+// has the form "topic=package.Type"; lines starting with # are comments.
+func LoadTypeMap(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open type map: %w", err)
+	}
+	defer file.Close()
+
+	typeMap := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		topic, typeName, ok := strings.Cut(line, "=")
+		topic = strings.TrimSpace(topic)
+		typeName = strings.TrimSpace(typeName)
+		if !ok || topic == "" || typeName == "" {
+			return nil, fmt.Errorf("%s:%d: expected topic=package.Type, got %q", path, lineNum, line)
+		}
+		if _, dup := typeMap[topic]; dup {
+			return nil, fmt.Errorf("%s:%d: topic %s mapped more than once", path, lineNum, topic)
+		}
+		typeMap[topic] = typeName
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read type map: %w", err)
+	}
+
+	return typeMap, nil
+}
+
+// ParseTypeHeader extracts a message type name from a record header value.
+// It accepts a bare type name ("events.OrderEvent"), a type URL
+// ("type.googleapis.com/events.OrderEvent"), or a content type with a
+// messageType or proto parameter
+// ("application/x-protobuf; messageType=events.OrderEvent"). It returns ""
+// if value names no type, as for a plain content type like application/json.
+func ParseTypeHeader(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+
+	if strings.Contains(value, ";") {
+		_, params, err := mime.ParseMediaType(value)
+		if err != nil {
+			return ""
+		}
+		// ParseMediaType lowercases parameter names.
+		for _, name := range []string{"messagetype", "proto"} {
+			if typeName := params[name]; typeName != "" {
+				return typeName
+			}
+		}
+		return ""
+	}
+
+	if i := strings.Index(value, "/"); i >= 0 {
+		// A content type without parameters names no message type; its
+		// subtype, as in application/x-protobuf, is not a type URL.
+		if mimeTopLevelTypes[strings.ToLower(value[:i])] {
+			return ""
+		}
+		value = value[strings.LastIndex(value, "/")+1:]
+		if !strings.Contains(value, ".") {
+			return ""
+		}
+	}
+	if !protoreflect.FullName(value).IsValid() {
+		return ""
+	}
+	return value
+}
+
+// mimeTopLevelTypes are the top-level media types, which distinguish content
+// types from type URLs such as type.googleapis.com/events.OrderEvent.
+var mimeTopLevelTypes = map[string]bool{
+	"application": true,
+	"audio":       true,
+	"font":        true,
+	"image":       true,
+	"message":     true,
+	"model":       true,
+	"multipart":   true,
+	"text":        true,
+	"video":       true,
+}
@@ -0,0 +1,117 @@
+package decoder
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// unknownKey is the key of the sidecar object that lists unknown fields.
+const unknownKey = "_unknown"
+
+// UnknownField is a field present in a payload but not declared in the
+// message type it was decoded as, typically because the producer uses a newer
+// schema.
+type UnknownField struct {
+	Number   protowire.Number `json:"field"`
+	WireType string           `json:"wire_type"`
+	// BytesHex holds the field's raw wire format, tags included, for every
+	// occurrence of the field in its message.
+	BytesHex string `json:"bytes_hex"`
+}
+
+// UnknownFields returns the unknown fields of m and its nested messages,
+// keyed by their path from m, such as "7" or "items[0].9". Path segments are
+// the field names used in the rendered JSON.
+func (d *Decoder) UnknownFields(m protoreflect.Message) map[string]UnknownField {
+	unknown := make(map[string]UnknownField)
+	d.collectUnknown(m, "", unknown)
+	return unknown
+}
+
+func (d *Decoder) collectUnknown(m protoreflect.Message, prefix string, unknown map[string]UnknownField) {
+	raw := m.GetUnknown()
+	for len(raw) > 0 {
+		num, typ, n := protowire.ConsumeTag(raw)
+		if n < 0 {
+			break
+		}
+		size := protowire.ConsumeFieldValue(num, typ, raw[n:])
+		if size < 0 {
+			break
+		}
+		path := prefix + strconv.Itoa(int(num))
+		field, ok := unknown[path]
+		if !ok {
+			field = UnknownField{Number: num, WireType: wireTypeName(typ)}
+		}
+		field.BytesHex += hex.EncodeToString(raw[:n+size])
+		unknown[path] = field
+		raw = raw[n+size:]
+	}
+
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.Kind() != protoreflect.MessageKind && fd.Kind() != protoreflect.GroupKind {
+			return true
+		}
+		name := d.fieldName(fd)
+		switch {
+		case fd.IsMap():
+			if fd.MapValue().Message() == nil {
+				return true
+			}
+			v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+				d.collectUnknown(mv.Message(), fmt.Sprintf("%s%s[%v].", prefix, name, k.Interface()), unknown)
+				return true
+			})
+		case fd.IsList():
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				d.collectUnknown(list.Get(i).Message(), fmt.Sprintf("%s%s[%d].", prefix, name, i), unknown)
+			}
+		default:
+			d.collectUnknown(v.Message(), prefix+name+".", unknown)
+		}
+		return true
+	})
+}
+
+// addUnknown adds the sidecar object of unknown fields to jsonData. Messages
+// that do not render as a JSON object, such as well-known types, are left as
+// they are.
+func addUnknown(jsonData []byte, unknown map[string]UnknownField) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(jsonData))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	obj, ok := value.(map[string]any)
+	if !ok {
+		return jsonData, nil
+	}
+	obj[unknownKey] = unknown
+	return json.MarshalIndent(obj, "", "  ")
+}
+
+func wireTypeName(typ protowire.Type) string {
+	switch typ {
+	case protowire.VarintType:
+		return "varint"
+	case protowire.Fixed32Type:
+		return "fixed32"
+	case protowire.Fixed64Type:
+		return "fixed64"
+	case protowire.BytesType:
+		return "bytes"
+	case protowire.StartGroupType:
+		return "group"
+	default:
+		return strconv.Itoa(int(typ))
+	}
+}
@@ -0,0 +1,86 @@
+package decoder
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestUnknownFields(t *testing.T) {
+	dec, err := NewDecoder(testDescriptorSet, "events.OrderEvent")
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+
+	// An OrderItem with product_id and an undeclared field 9.
+	var item []byte
+	item = protowire.AppendTag(item, 1, protowire.BytesType)
+	item = protowire.AppendString(item, "p-1")
+	item = protowire.AppendTag(item, 9, protowire.BytesType)
+	item = protowire.AppendString(item, "new")
+
+	var payload []byte
+	payload = protowire.AppendTag(payload, 1, protowire.BytesType)
+	payload = protowire.AppendString(payload, "o-1")
+	payload = protowire.AppendTag(payload, 5, protowire.BytesType)
+	payload = protowire.AppendBytes(payload, item)
+	payload = protowire.AppendTag(payload, 99, protowire.VarintType)
+	payload = protowire.AppendVarint(payload, 1)
+	payload = protowire.AppendTag(payload, 99, protowire.VarintType)
+	payload = protowire.AppendVarint(payload, 2)
+
+	jsonData, _, err := dec.Decode(payload)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	var plain map[string]any
+	if err := json.Unmarshal(jsonData, &plain); err != nil {
+		t.Fatalf("invalid JSON %s: %v", jsonData, err)
+	}
+	if _, ok := plain[unknownKey]; ok {
+		t.Errorf("unknown fields shown without ShowUnknown: %s", jsonData)
+	}
+	if got := dec.UnknownMessageCount(); got != 1 {
+		t.Errorf("UnknownMessageCount = %d, want 1", got)
+	}
+
+	dec.SetJSONOptions(JSONOptions{ShowUnknown: true})
+	jsonData, _, err = dec.Decode(payload)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	var got struct {
+		OrderID string                  `json:"order_id"`
+		Unknown map[string]UnknownField `json:"_unknown"`
+	}
+	if err := json.Unmarshal(jsonData, &got); err != nil {
+		t.Fatalf("invalid JSON %s: %v", jsonData, err)
+	}
+	if got.OrderID != "o-1" {
+		t.Errorf("order_id = %q, want o-1", got.OrderID)
+	}
+	want := map[string]UnknownField{
+		"99":         {Number: 99, WireType: "varint", BytesHex: "980601980602"},
+		"items[0].9": {Number: 9, WireType: "bytes", BytesHex: "4a036e6577"},
+	}
+	if !reflect.DeepEqual(got.Unknown, want) {
+		t.Errorf("_unknown = %+v, want %+v", got.Unknown, want)
+	}
+
+	// A payload that matches its type has no sidecar.
+	jsonData, _, err = dec.Decode(item[:5])
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if err := json.Unmarshal(jsonData, &plain); err != nil {
+		t.Fatalf("invalid JSON %s: %v", jsonData, err)
+	}
+	if _, ok := plain[unknownKey]; ok {
+		t.Errorf("sidecar added to a payload without unknown fields: %s", jsonData)
+	}
+	if got := dec.UnknownMessageCount(); got != 2 {
+		t.Errorf("UnknownMessageCount = %d, want 2", got)
+	}
+}
@@ -16,9 +16,54 @@ type Message struct {
 	Key         string
 	Timestamp   time.Time
 	MessageType string
-	Value       interface{}
-	Error       string
-	RawValue    []byte
+	// Confidence is set when MessageType was inferred rather than given.
+	Confidence float64
+	// Value is the decoded message, or its schemaless wire structure when
+	// Error is set and the payload is still valid protobuf.
+	Value    interface{}
+	Error    string
+	RawValue []byte
+	Headers  []Header
+	// KeyValue is the structured form of Key for JSON output, set for keys
+	// decoded as a number or a message.
+	KeyValue interface{}
+	// Protoscope is the wire format of the value in protoscope syntax, set
+	// for the protoscope format.
+	Protoscope string
+}
+
+// Header is a record header.
+type Header struct {
+	Key string
+	// Value is the decoded message for headers of a known message type, or
+	// the header's text. It is nil for binary values, which are only shown
+	// from RawValue.
+	Value    interface{}
+	RawValue []byte
+}
+
+// jsonValue returns the header's value for JSON output along with its key:
+// "value", or "value_hex" for binary values.
+func (h Header) jsonValue() (string, interface{}) {
+	if h.Value == nil {
+		return "value_hex", hex.EncodeToString(h.RawValue)
+	}
+	return "value", h.Value
+}
+
+// text renders the header's value on a single line.
+func (h Header) text() string {
+	switch v := h.Value.(type) {
+	case nil:
+		return "0x" + hex.EncodeToString(h.RawValue)
+	case string:
+		return v
+	default:
+		if jsonBytes, err := json.Marshal(v); err == nil {
+			return string(jsonBytes)
+		}
+		return fmt.Sprintf("%v", v)
+	}
 }
 
 type Formatter interface {
@@ -37,6 +82,8 @@ func New(format string) (Formatter, error) {
 		return &RawFormatter{}, nil
 	case "pretty":
 		return &PrettyFormatter{}, nil
+	case "protoscope":
+		return &ProtoscopeFormatter{}, nil
 	default:
 		return nil, fmt.Errorf("unknown format: %s", format)
 	}
@@ -54,14 +101,31 @@ func (f *JSONFormatter) Format(msg Message) error {
 		"timestamp": msg.Timestamp.Format(time.RFC3339),
 		"key":       msg.Key,
 	}
+	if msg.KeyValue != nil {
+		output["key"] = msg.KeyValue
+	}
 
 	if msg.MessageType != "" {
 		output["message_type"] = msg.MessageType
 	}
+	if msg.Confidence > 0 {
+		output["type_confidence"] = msg.Confidence
+	}
+	if len(msg.Headers) > 0 {
+		headers := make([]map[string]interface{}, len(msg.Headers))
+		for i, h := range msg.Headers {
+			key, value := h.jsonValue()
+			headers[i] = map[string]interface{}{"key": h.Key, key: value}
+		}
+		output["headers"] = headers
+	}
 
 	if msg.Error != "" {
 		output["error"] = msg.Error
 		output["raw_value_hex"] = hex.EncodeToString(msg.RawValue)
+		if msg.Value != nil {
+			output["raw_decoded"] = msg.Value
+		}
 	} else {
 		output["value"] = msg.Value
 	}
@@ -92,12 +156,28 @@ func (f *TableFormatter) Format(msg Message) error {
 	fmt.Printf("Key:         %s\n", msg.Key)
 
 	if msg.MessageType != "" {
-		fmt.Printf("Type:        %s\n", msg.MessageType)
+		if msg.Confidence > 0 {
+			fmt.Printf("Type:        %s (inferred, %.0f%% confidence)\n", msg.MessageType, msg.Confidence*100)
+		} else {
+			fmt.Printf("Type:        %s\n", msg.MessageType)
+		}
+	}
+	if len(msg.Headers) > 0 {
+		fmt.Printf("Headers:\n")
+		for _, h := range msg.Headers {
+			fmt.Printf("  %s: %s\n", h.Key, h.text())
+		}
 	}
 
 	if msg.Error != "" {
 		fmt.Printf("Error:       %s\n", msg.Error)
-		fmt.Printf("Raw (hex):   %s\n", hex.EncodeToString(msg.RawValue)[:100]+"...")
+		fmt.Printf("Raw (hex):   %s\n", truncateHex(msg.RawValue, 100))
+		if msg.Value != nil {
+			fmt.Printf("Raw decode:\n")
+			if jsonBytes, err := json.MarshalIndent(msg.Value, "", "  "); err == nil {
+				fmt.Println(string(jsonBytes))
+			}
+		}
 	} else {
 		fmt.Printf("Value:\n")
 		if jsonBytes, err := json.MarshalIndent(msg.Value, "", "  "); err == nil {
@@ -141,14 +221,25 @@ func (f *PrettyFormatter) Format(msg Message) error {
 
 	if msg.MessageType != "" {
 		header += fmt.Sprintf(" type=%s", shortTypeName(msg.MessageType))
+		if msg.Confidence > 0 {
+			header += fmt.Sprintf(" (%.0f%%)", msg.Confidence*100)
+		}
 	}
 
 	fmt.Printf("%s%s%s\n", "\033[36m", header, "\033[0m") // Cyan header
+	for _, h := range msg.Headers {
+		fmt.Printf("\033[90m%s: %s\033[0m\n", h.Key, h.text()) // Gray headers
+	}
 
 	if msg.Error != "" {
 		fmt.Printf("\033[31mError: %s\033[0m\n", msg.Error) // Red error
 		if len(msg.RawValue) > 0 {
-			fmt.Printf("Raw: %s...\n", hex.EncodeToString(msg.RawValue)[:min(100, len(msg.RawValue)*2)])
+			fmt.Printf("Raw: %s\n", truncateHex(msg.RawValue, 100))
+		}
+		if msg.Value != nil {
+			if jsonBytes, err := json.MarshalIndent(msg.Value, "", "  "); err == nil {
+				fmt.Println(string(jsonBytes))
+			}
 		}
 	} else {
 		if jsonBytes, err := json.MarshalIndent(msg.Value, "", "  "); err == nil {
@@ -161,6 +252,37 @@ func (f *PrettyFormatter) Format(msg Message) error {
 	return nil
 }
 
+// ProtoscopeFormatter prints the raw wire format of each value in protoscope
+// syntax, independent of any decoded message type.
+type ProtoscopeFormatter struct{}
+
+func (f *ProtoscopeFormatter) Format(msg Message) error {
+	header := fmt.Sprintf("# %s/%d@%d", msg.Topic, msg.Partition, msg.Offset)
+	if msg.Key != "" {
+		header += fmt.Sprintf(" key=%s", msg.Key)
+	}
+	if msg.MessageType != "" {
+		header += fmt.Sprintf(" type=%s", msg.MessageType)
+	}
+	fmt.Println(header)
+
+	if msg.Protoscope == "" {
+		fmt.Printf("`%s`\n", hex.EncodeToString(msg.RawValue))
+		return nil
+	}
+	fmt.Print(msg.Protoscope)
+	return nil
+}
+
+// truncateHex hex-encodes data, cutting it to at most limit characters.
+func truncateHex(data []byte, limit int) string {
+	h := hex.EncodeToString(data)
+	if len(h) <= limit {
+		return h
+	}
+	return h[:limit] + "..."
+}
+
 func shortTypeName(fullName string) string {
 	parts := strings.Split(fullName, ".")
 	if len(parts) > 0 {
@@ -168,10 +290,3 @@ func shortTypeName(fullName string) string {
 	}
 	return fullName
 }
-
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
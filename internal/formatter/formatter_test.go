@@ -20,6 +20,7 @@ func TestNew(t *testing.T) {
 		{"Table format", "table", false},
 		{"Pretty format", "pretty", false},
 		{"Raw format", "raw", false},
+		{"Protoscope format", "protoscope", false},
 		{"Unknown format", "unknown", true},
 	}
 
@@ -251,6 +252,124 @@ func TestFormatterWithError(t *testing.T) {
 	}
 }
 
+func TestFormatterWithInferredType(t *testing.T) {
+	// Capture stdout
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	formatter, _ := New("json")
+	msg := Message{
+		Topic:       "test-topic",
+		Partition:   1,
+		Offset:      100,
+		Value:       map[string]string{"field": "value"},
+		MessageType: "TestMessage",
+		Confidence:  0.75,
+	}
+
+	err := formatter.Format(msg)
+	if err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	// Restore stdout and read output
+	w.Close()
+	os.Stdout = oldStdout
+	output, _ := io.ReadAll(r)
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(output, &result); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v\nOutput: %s", err, output)
+	}
+
+	if result["type_confidence"] != 0.75 {
+		t.Errorf("Expected type_confidence 0.75, got %v", result["type_confidence"])
+	}
+}
+
+func TestProtoscopeFormatter(t *testing.T) {
+	// Capture stdout
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	formatter, _ := New("protoscope")
+	msg := Message{
+		Topic:      "test-topic",
+		Partition:  1,
+		Offset:     100,
+		Key:        "test-key",
+		RawValue:   []byte{0x08, 0x96, 0x01, 0x12, 0x02, 'h', 'i'},
+		Protoscope: "1: 150\n2: {\"hi\"}\n",
+	}
+
+	err := formatter.Format(msg)
+	if err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	// Restore stdout and read output
+	w.Close()
+	os.Stdout = oldStdout
+	output, _ := io.ReadAll(r)
+	outputStr := string(output)
+
+	for _, expected := range []string{"# test-topic/1@100 key=test-key", "1: 150", `2: {"hi"}`} {
+		if !strings.Contains(outputStr, expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, outputStr)
+		}
+	}
+}
+
+func TestFormatterHeaders(t *testing.T) {
+	msg := Message{
+		Topic:     "test-topic",
+		Partition: 1,
+		Offset:    100,
+		Value:     map[string]string{"field": "value"},
+		Headers: []Header{
+			{Key: "trace-id", Value: "abc", RawValue: []byte("abc")},
+			{Key: "meta", Value: map[string]string{"source": "web"}, RawValue: []byte{0x0a}},
+			{Key: "binary", RawValue: []byte{0xff, 0x00}},
+		},
+	}
+
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{"json", []string{`"headers": [`, `"key": "trace-id"`, `"value": "abc"`, `"source": "web"`, `"value_hex": "ff00"`}},
+		{"table", []string{"Headers:", "  trace-id: abc", `  meta: {"source":"web"}`, "  binary: 0xff00"}},
+		{"pretty", []string{"trace-id: abc", `meta: {"source":"web"}`, "binary: 0xff00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			// Capture stdout
+			oldStdout := os.Stdout
+			r, w, _ := os.Pipe()
+			os.Stdout = w
+
+			formatter, _ := New(tt.format)
+			err := formatter.Format(msg)
+
+			// Restore stdout and read output
+			w.Close()
+			os.Stdout = oldStdout
+			output, _ := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+
+			for _, expected := range tt.want {
+				if !strings.Contains(string(output), expected) {
+					t.Errorf("Expected output to contain %q, got:\n%s", expected, output)
+				}
+			}
+		})
+	}
+}
+
 func TestShortTypeName(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -0,0 +1,130 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// Special timestamps understood by ListOffsets.
+const (
+	listOffsetsEarliest int64 = -2
+	listOffsetsLatest   int64 = -1
+)
+
+// listTopics returns the sorted names of all non-internal topics.
+func listTopics(ctx context.Context, client *kgo.Client) ([]string, error) {
+	req := kmsg.NewPtrMetadataRequest()
+	resp, err := req.RequestWith(ctx, client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch metadata: %w", err)
+	}
+
+	var topics []string
+	for _, t := range resp.Topics {
+		if t.IsInternal || t.Topic == nil {
+			continue
+		}
+		if err := kerr.ErrorForCode(t.ErrorCode); err != nil {
+			return nil, fmt.Errorf("failed to fetch metadata for topic %s: %w", *t.Topic, err)
+		}
+		topics = append(topics, *t.Topic)
+	}
+	sort.Strings(topics)
+	return topics, nil
+}
+
+// listPartitions returns the sorted partition IDs of topic.
+func listPartitions(ctx context.Context, client *kgo.Client, topic string) ([]int32, error) {
+	req := kmsg.NewPtrMetadataRequest()
+	reqTopic := kmsg.NewMetadataRequestTopic()
+	reqTopic.Topic = kmsg.StringPtr(topic)
+	req.Topics = append(req.Topics, reqTopic)
+
+	resp, err := req.RequestWith(ctx, client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch metadata for topic %s: %w", topic, err)
+	}
+
+	var partitions []int32
+	for _, t := range resp.Topics {
+		if err := kerr.ErrorForCode(t.ErrorCode); err != nil {
+			return nil, fmt.Errorf("failed to fetch metadata for topic %s: %w", topic, err)
+		}
+		for _, p := range t.Partitions {
+			partitions = append(partitions, p.Partition)
+		}
+	}
+	if len(partitions) == 0 {
+		return nil, fmt.Errorf("topic %s has no partitions", topic)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+	return partitions, nil
+}
+
+// listOffsets resolves a timestamp to an offset for each partition of topic.
+// The timestamp may be listOffsetsEarliest, listOffsetsLatest, or Unix
+// milliseconds; in the latter case partitions without any record at or after
+// the timestamp resolve to their high watermark.
+func listOffsets(ctx context.Context, client *kgo.Client, topic string, partitions []int32, timestamp int64) (map[int32]int64, error) {
+	offsets, err := requestListOffsets(ctx, client, topic, partitions, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	if timestamp < 0 {
+		return offsets, nil
+	}
+
+	var missing []int32
+	for _, p := range partitions {
+		if offsets[p] < 0 {
+			missing = append(missing, p)
+		}
+	}
+	if len(missing) == 0 {
+		return offsets, nil
+	}
+	latest, err := requestListOffsets(ctx, client, topic, missing, listOffsetsLatest)
+	if err != nil {
+		return nil, err
+	}
+	for p, o := range latest {
+		offsets[p] = o
+	}
+	return offsets, nil
+}
+
+func requestListOffsets(ctx context.Context, client *kgo.Client, topic string, partitions []int32, timestamp int64) (map[int32]int64, error) {
+	req := kmsg.NewPtrListOffsetsRequest()
+	req.ReplicaID = -1
+	reqTopic := kmsg.NewListOffsetsRequestTopic()
+	reqTopic.Topic = topic
+	for _, p := range partitions {
+		reqPartition := kmsg.NewListOffsetsRequestTopicPartition()
+		reqPartition.Partition = p
+		reqPartition.CurrentLeaderEpoch = -1
+		reqPartition.Timestamp = timestamp
+		reqTopic.Partitions = append(reqTopic.Partitions, reqPartition)
+	}
+	req.Topics = append(req.Topics, reqTopic)
+
+	resp, err := req.RequestWith(ctx, client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list offsets for topic %s: %w", topic, err)
+	}
+
+	offsets := make(map[int32]int64, len(partitions))
+	for _, t := range resp.Topics {
+		for _, p := range t.Partitions {
+			if err := kerr.ErrorForCode(p.ErrorCode); err != nil {
+				return nil, fmt.Errorf("failed to list offsets for %s/%d: %w", topic, p.Partition, err)
+			}
+			offsets[p.Partition] = p.Offset
+		}
+	}
+	return offsets, nil
+}
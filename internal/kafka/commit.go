@@ -0,0 +1,99 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Offset commit modes for ConsumerConfig.Commit.
+const (
+	CommitNone       = "none"
+	CommitAfterPrint = "after-print"
+	CommitPeriodic   = "periodic"
+)
+
+// commitTimeout bounds a single offset commit, which may run after the
+// consume context has already been canceled.
+const commitTimeout = 10 * time.Second
+
+// committer is the part of *kgo.Client that commits offsets.
+type committer interface {
+	CommitRecords(ctx context.Context, rs ...*kgo.Record) error
+	MarkCommitRecords(rs ...*kgo.Record)
+	CommitMarkedOffsets(ctx context.Context) error
+}
+
+// commitPlan decides when the offsets of handled records are committed.
+type commitPlan struct {
+	mode string
+	// interval is the autocommit interval for CommitPeriodic, or zero for
+	// the client's default.
+	interval time.Duration
+}
+
+// newCommitPlan validates a commit mode. Any mode but CommitNone needs a
+// consumer group.
+func newCommitPlan(mode, group string, interval time.Duration) (commitPlan, error) {
+	switch mode {
+	case "", CommitNone:
+		return commitPlan{mode: CommitNone}, nil
+	case CommitAfterPrint, CommitPeriodic:
+		if group == "" {
+			return commitPlan{}, fmt.Errorf("--commit %s requires a consumer group (--group)", mode)
+		}
+	default:
+		return commitPlan{}, fmt.Errorf("invalid commit mode %q: expected none, after-print, or periodic", mode)
+	}
+	p := commitPlan{mode: mode}
+	if mode == CommitPeriodic && interval > 0 {
+		p.interval = interval
+	}
+	return p, nil
+}
+
+// options returns the client options for consuming in a group with the plan.
+func (p commitPlan) options() []kgo.Opt {
+	if p.mode != CommitPeriodic {
+		return []kgo.Opt{kgo.DisableAutoCommit()}
+	}
+	// Only records that have been handled are marked, so a periodic commit
+	// never skips over output the user has not seen.
+	opts := []kgo.Opt{kgo.AutoCommitMarks()}
+	if p.interval > 0 {
+		opts = append(opts, kgo.AutoCommitInterval(p.interval))
+	}
+	return opts
+}
+
+// commit records the progress of handled records: synchronously for
+// CommitAfterPrint, or by marking them for the next autocommit for
+// CommitPeriodic.
+func (p commitPlan) commit(client committer, records []*kgo.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	switch p.mode {
+	case CommitAfterPrint:
+		ctx, cancel := context.WithTimeout(context.Background(), commitTimeout)
+		defer cancel()
+		return client.CommitRecords(ctx, records...)
+	case CommitPeriodic:
+		client.MarkCommitRecords(records...)
+	}
+	return nil
+}
+
+// flush commits offsets marked since the last periodic autocommit so that a
+// subsequent run with --offset stored resumes right after the last printed
+// record.
+func (p commitPlan) flush(client committer) error {
+	if p.mode != CommitPeriodic {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), commitTimeout)
+	defer cancel()
+	return client.CommitMarkedOffsets(ctx)
+}
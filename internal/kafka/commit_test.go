@@ -0,0 +1,124 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// fakeCommitter records the commit calls made by a commitPlan.
+type fakeCommitter struct {
+	committed [][]*kgo.Record
+	marked    []*kgo.Record
+	flushes   int
+	err       error
+}
+
+func (f *fakeCommitter) CommitRecords(_ context.Context, rs ...*kgo.Record) error {
+	f.committed = append(f.committed, rs)
+	return f.err
+}
+
+func (f *fakeCommitter) MarkCommitRecords(rs ...*kgo.Record) {
+	f.marked = append(f.marked, rs...)
+}
+
+func (f *fakeCommitter) CommitMarkedOffsets(context.Context) error {
+	f.flushes++
+	return f.err
+}
+
+func TestCommitPlan(t *testing.T) {
+	first := []*kgo.Record{{Topic: "events", Partition: 0, Offset: 1}, {Topic: "events", Partition: 1, Offset: 7}}
+	second := []*kgo.Record{{Topic: "events", Partition: 0, Offset: 2}}
+
+	tests := []struct {
+		name          string
+		mode          string
+		interval      time.Duration
+		wantInterval  time.Duration
+		wantCommitted [][]*kgo.Record
+		wantMarked    []*kgo.Record
+		wantFlushes   int
+	}{
+		// The client never commits on its own; nothing is committed.
+		{"none", "", 5 * time.Second, 0, nil, nil, 0},
+		// Every handled batch is committed synchronously.
+		{"after print", CommitAfterPrint, 5 * time.Second, 0, [][]*kgo.Record{first, second}, nil, 0},
+		// Handled records are marked for the autocommit every interval, and
+		// flushed once more on exit.
+		{"periodic", CommitPeriodic, 2 * time.Second, 2 * time.Second, nil, append(append([]*kgo.Record{}, first...), second...), 1},
+		{"periodic with default interval", CommitPeriodic, 0, 0, nil, append(append([]*kgo.Record{}, first...), second...), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newCommitPlan(tt.mode, "debug", tt.interval)
+			if err != nil {
+				t.Fatalf("newCommitPlan failed: %v", err)
+			}
+			if p.interval != tt.wantInterval {
+				t.Errorf("interval = %v, want %v", p.interval, tt.wantInterval)
+			}
+			wantOptions := 1
+			if tt.wantInterval > 0 {
+				wantOptions = 2
+			}
+			if got := len(p.options()); got != wantOptions {
+				t.Errorf("options() returned %d options, want %d", got, wantOptions)
+			}
+
+			client := &fakeCommitter{}
+			for _, batch := range [][]*kgo.Record{first, nil, second} {
+				if err := p.commit(client, batch); err != nil {
+					t.Fatalf("commit failed: %v", err)
+				}
+			}
+			if err := p.flush(client); err != nil {
+				t.Fatalf("flush failed: %v", err)
+			}
+			if !reflect.DeepEqual(client.committed, tt.wantCommitted) {
+				t.Errorf("committed = %v, want %v", client.committed, tt.wantCommitted)
+			}
+			if !reflect.DeepEqual(client.marked, tt.wantMarked) {
+				t.Errorf("marked = %v, want %v", client.marked, tt.wantMarked)
+			}
+			if client.flushes != tt.wantFlushes {
+				t.Errorf("flushes = %d, want %d", client.flushes, tt.wantFlushes)
+			}
+		})
+	}
+
+	failing := &fakeCommitter{err: errors.New("rebalancing")}
+	p, _ := newCommitPlan(CommitAfterPrint, "debug", 0)
+	if err := p.commit(failing, first); err == nil {
+		t.Error("commit did not report a failed commit")
+	}
+	p, _ = newCommitPlan(CommitPeriodic, "debug", 0)
+	if err := p.flush(failing); err == nil {
+		t.Error("flush did not report a failed commit")
+	}
+
+	errTests := []struct {
+		name    string
+		mode    string
+		group   string
+		wantErr string
+	}{
+		{"after print without group", CommitAfterPrint, "", "requires a consumer group"},
+		{"periodic without group", CommitPeriodic, "", "requires a consumer group"},
+		{"unknown mode", "always", "debug", "invalid commit mode"},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newCommitPlan(tt.mode, tt.group, 0)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("newCommitPlan error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,16 +1,24 @@
 package kafka
 
 import (
+	"bytes"
 	"context"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"regexp"
+	"sort"
 	"strings"
 	"syscall"
+	"time"
+	"unicode/utf8"
 
 	"github.com/HurSungYun/buf-kcat/internal/decoder"
 	"github.com/HurSungYun/buf-kcat/internal/formatter"
+	"github.com/HurSungYun/buf-kcat/internal/registry"
 	"github.com/twmb/franz-go/pkg/kgo"
 )
 
@@ -18,15 +26,48 @@ import (
 type ConsumerConfig struct {
 	Brokers      []string
 	Group        string
-	Topic        string
+	Topics       []string
+	TopicRegex   string
+	Partitions   []int32
 	ProtoPath    string
+	ImportPaths  []string
+	CacheDir     string
+	Strict       bool
 	MessageType  string
+	TypeMap      map[string]string
+	TypeMapFile  string
+	TypeHeader   string
+	HeaderTypes  map[string]string
+	DecodeRaw    bool
 	OutputFormat string
 	Offset       string
+	Until        string
 	Count        int
 	Follow       bool
 	KeyFilter    string
-	Verbose      bool
+	// KeyFile, KeyPrefix and KeyRegex narrow the consumed records by their
+	// raw key along with KeyFilter. Keys and prefixes may be written as
+	// hex:... or base64:... literals.
+	KeyFile   string
+	KeyPrefix string
+	KeyRegex  string
+	// KeyFormat is one of the KeyFormat constants; KeyType instead decodes
+	// keys as a message type.
+	KeyFormat string
+	KeyType   string
+	// Filter is a CEL expression over the fields of the decoded value; only
+	// records for which it is true are printed and counted.
+	Filter string
+	// JSON controls how decoded values are rendered by every output format.
+	JSON decoder.JSONOptions
+	// SchemaRegistry is the URL of a Confluent Schema Registry used to look
+	// up the schema of Confluent-framed payloads. ProtoPath may then be empty.
+	SchemaRegistry string
+	// Commit is one of CommitNone, CommitAfterPrint or CommitPeriodic and
+	// requires Group when set to anything but CommitNone.
+	Commit         string
+	CommitInterval time.Duration
+	Verbose        bool
 }
 
 // Consumer consumes messages, decodes them using protobuf and formats output.
@@ -35,44 +76,210 @@ type Consumer struct {
 	decoder   *decoder.Decoder
 	formatter formatter.Formatter
 	cfg       ConsumerConfig
+	start     startOffset
+	until     endBound
+	commits   commitPlan
+	ends      *endOffsets
+	registry  *registry.Client
+
+	// partitionStarts holds the start offset of every explicitly selected
+	// partition, applied to each topic. It is nil when consuming all
+	// partitions.
+	partitionStarts map[int32]startOffset
+	topicRegex      *regexp.Regexp
+	keys            keyCodec
+	keyMatcher      *keyMatcher
+	// headerTypes maps lowercased header names to the message type their
+	// values are decoded as.
+	headerTypes map[string]string
 }
 
 // NewConsumer initializes a Consumer.
 func NewConsumer(cfg ConsumerConfig) (*Consumer, error) {
-	if cfg.Topic == "" {
+	if len(cfg.Topics) == 0 && cfg.TopicRegex == "" {
 		return nil, fmt.Errorf("topic is required")
 	}
-	if cfg.MessageType == "" {
-		return nil, fmt.Errorf("message type is required")
+	if len(cfg.Topics) > 0 && cfg.TopicRegex != "" {
+		return nil, fmt.Errorf("--topic cannot be combined with --topic-regex")
+	}
+	var topicRegex *regexp.Regexp
+	if cfg.TopicRegex != "" {
+		re, err := regexp.Compile(cfg.TopicRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid topic regex: %w", err)
+		}
+		topicRegex = re
+	}
+	typeMap := make(map[string]string)
+	if cfg.TypeMapFile != "" {
+		fileMap, err := decoder.LoadTypeMap(cfg.TypeMapFile)
+		if err != nil {
+			return nil, err
+		}
+		for topic, typeName := range fileMap {
+			typeMap[topic] = typeName
+		}
+	}
+	for topic, typeName := range cfg.TypeMap {
+		typeMap[topic] = typeName
+	}
+	var schemaRegistry *registry.Client
+	if cfg.SchemaRegistry != "" {
+		var err error
+		schemaRegistry, err = registry.NewClient(cfg.SchemaRegistry)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cfg.DecodeRaw && len(cfg.HeaderTypes) > 0 {
+		return nil, fmt.Errorf("--header-type cannot be combined with --decode-raw")
+	}
+	if cfg.DecodeRaw && cfg.Filter != "" {
+		return nil, fmt.Errorf("--filter cannot be combined with --decode-raw")
+	}
+	// Schemaless decoding needs no descriptors at all.
+	var dec *decoder.Decoder
+	if !cfg.DecodeRaw {
+		if cfg.MessageType == "" && len(typeMap) == 0 && cfg.TypeHeader == "" && schemaRegistry == nil {
+			return nil, fmt.Errorf("message type is required")
+		}
+		var err error
+		dec, err = decoder.NewDecoderWithOptions(cfg.ProtoPath, cfg.MessageType, decoder.Options{
+			ImportPaths: cfg.ImportPaths,
+			CacheDir:    cfg.CacheDir,
+			Strict:      cfg.Strict,
+			JSON:        cfg.JSON,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize decoder: %w", err)
+		}
+		if err := dec.SetTopicTypes(typeMap); err != nil {
+			return nil, fmt.Errorf("invalid type map: %w", err)
+		}
+		for name, typeName := range cfg.HeaderTypes {
+			if err := dec.CheckType(typeName); err != nil {
+				return nil, fmt.Errorf("invalid header type for %s: %w", name, err)
+			}
+		}
+		if cfg.Filter != "" {
+			if err := dec.SetFilter(cfg.Filter); err != nil {
+				return nil, err
+			}
+		}
+		if schemaRegistry != nil {
+			dec.SetSchemaSource(schemaRegistry)
+		}
+	}
+	keys, err := newKeyCodec(cfg.KeyFormat, cfg.KeyType, dec)
+	if err != nil {
+		return nil, err
 	}
-	dec, err := decoder.NewDecoder(cfg.ProtoPath, cfg.MessageType)
+	matcher, err := newKeyMatcher(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize decoder: %w", err)
+		return nil, err
+	}
+	headerTypes := make(map[string]string, len(cfg.HeaderTypes))
+	for name, typeName := range cfg.HeaderTypes {
+		headerTypes[strings.ToLower(name)] = typeName
 	}
 	fmtr, err := formatter.New(cfg.OutputFormat)
 	if err != nil {
 		return nil, fmt.Errorf("invalid output format: %w", err)
 	}
 
+	now := time.Now()
+	partitionStarts, err := parsePartitionOffsets(cfg.Offset, now)
+	if err != nil {
+		return nil, err
+	}
+	var start startOffset
+	if partitionStarts != nil {
+		if len(cfg.Partitions) > 0 {
+			return nil, fmt.Errorf("--partition cannot be combined with per-partition offsets")
+		}
+	} else {
+		start, err = parseStartOffset(cfg.Offset, now)
+		if err != nil {
+			return nil, err
+		}
+		if len(cfg.Partitions) > 0 {
+			partitionStarts = make(map[int32]startOffset, len(cfg.Partitions))
+			for _, p := range cfg.Partitions {
+				partitionStarts[p] = start
+			}
+		}
+	}
+	until, err := parseEndBound(cfg.Until, now)
+	if err != nil {
+		return nil, err
+	}
+	if until.kind != untilNone && cfg.Group != "" {
+		// Partitions assigned to other members of the group, or already
+		// committed past the end, would never be seen to finish.
+		return nil, fmt.Errorf("--until cannot be combined with --group")
+	}
+	commits, err := newCommitPlan(cfg.Commit, cfg.Group, cfg.CommitInterval)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Commit = commits.mode
+
 	opts := []kgo.Opt{
 		kgo.SeedBrokers(cfg.Brokers...),
-		kgo.ConsumerGroup(cfg.Group),
-		kgo.ConsumeTopics(cfg.Topic),
-		kgo.DisableAutoCommit(),
+	}
+	if topicRegex != nil {
+		opts = append(opts, kgo.ConsumeRegex())
+	}
+	topics := cfg.Topics
+	if topicRegex != nil {
+		topics = []string{cfg.TopicRegex}
+	}
+	if partitionStarts != nil {
+		if cfg.Group != "" {
+			return nil, fmt.Errorf("--group cannot be combined with explicit partitions")
+		}
+		if topicRegex != nil {
+			return nil, fmt.Errorf("--topic-regex cannot be combined with explicit partitions")
+		}
+		// Assign partitions directly so that inspecting a single partition
+		// never joins, rebalances, or commits for any consumer group.
+		offsets := make(map[int32]kgo.Offset, len(partitionStarts))
+		for p, s := range partitionStarts {
+			if s.stored {
+				return nil, fmt.Errorf("stored offsets require a consumer group (--group) and cannot be used with explicit partitions")
+			}
+			offsets[p] = s.offset
+		}
+		assignments := make(map[string]map[int32]kgo.Offset, len(cfg.Topics))
+		for _, topic := range cfg.Topics {
+			assignments[topic] = offsets
+		}
+		opts = append(opts, kgo.ConsumePartitions(assignments))
+	} else if cfg.Group != "" {
+		opts = append(opts,
+			kgo.ConsumerGroup(cfg.Group),
+			kgo.ConsumeTopics(topics...),
+		)
+		opts = append(opts, commits.options()...)
+		if !start.stored {
+			opts = append(opts, kgo.ConsumeResetOffset(start.offset))
+		}
+	} else {
+		// Without a group, every partition is consumed directly,
+		// so concurrent runs never rebalance each other or show up as lag.
+		if start.stored {
+			return nil, fmt.Errorf("stored offsets require a consumer group (--group)")
+		}
+		opts = append(opts,
+			kgo.ConsumeTopics(topics...),
+			kgo.ConsumeResetOffset(start.offset),
+		)
 	}
 
-	switch {
-	case strings.HasPrefix(cfg.Offset, "timestamp:"):
-		// For simplicity, using end for now
-		fmt.Fprintf(os.Stderr, "Warning: timestamp offset not implemented, falling back to 'end'\n")
-		opts = append(opts, kgo.ConsumeResetOffset(kgo.NewOffset().AtEnd()))
-	case cfg.Offset == "beginning":
-		opts = append(opts, kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()))
-	case cfg.Offset == "stored":
-		// default behavior
-	default: // "end"
-		fmt.Fprintf(os.Stderr, "Info: using default offset 'end'\n")
-		opts = append(opts, kgo.ConsumeResetOffset(kgo.NewOffset().AtEnd()))
+	if until.kind != untilNone {
+		// Transaction markers occupy offsets too; without them a
+		// partition ending in a marker would never be seen to finish.
+		opts = append(opts, kgo.KeepControlRecords())
 	}
 
 	client, err := kgo.NewClient(opts...)
@@ -85,6 +292,16 @@ func NewConsumer(cfg ConsumerConfig) (*Consumer, error) {
 		decoder:   dec,
 		formatter: fmtr,
 		cfg:       cfg,
+		start:     start,
+		until:     until,
+		commits:   commits,
+		registry:  schemaRegistry,
+
+		partitionStarts: partitionStarts,
+		topicRegex:      topicRegex,
+		keys:            keys,
+		keyMatcher:      matcher,
+		headerTypes:     headerTypes,
 	}, nil
 }
 
@@ -108,22 +325,73 @@ func (c *Consumer) Run(ctx context.Context) error {
 	}()
 
 	fmt.Fprintf(os.Stderr, "Connected to Kafka brokers: %v\n", c.cfg.Brokers)
-	fmt.Fprintf(os.Stderr, "Starting to consume from topic '%s' (group: %s, offset: %s)\n", c.cfg.Topic, c.cfg.Group, c.cfg.Offset)
-	if c.cfg.MessageType != "" {
+	if c.partitionStarts != nil {
+		if err := c.validatePartitions(ctx); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Starting to consume from %s (partitions: %v, offset: %s)\n", c.describeTopics(), c.assignedPartitions(), c.cfg.Offset)
+	} else if c.cfg.Group != "" {
+		fmt.Fprintf(os.Stderr, "Starting to consume from %s (group: %s, offset: %s)\n", c.describeTopics(), c.cfg.Group, c.cfg.Offset)
+	} else {
+		fmt.Fprintf(os.Stderr, "Starting to consume from %s (all partitions, offset: %s)\n", c.describeTopics(), c.cfg.Offset)
+	}
+	if c.cfg.DecodeRaw {
+		fmt.Fprintf(os.Stderr, "Decoding raw wire format without descriptors\n")
+	} else if c.cfg.MessageType != "" {
 		fmt.Fprintf(os.Stderr, "Message type: %s\n", c.cfg.MessageType)
 	}
-	if c.cfg.KeyFilter != "" {
-		fmt.Fprintf(os.Stderr, "Filtering by key: %s\n", c.cfg.KeyFilter)
+	if c.cfg.TypeHeader != "" {
+		fmt.Fprintf(os.Stderr, "Message type from header: %s\n", c.cfg.TypeHeader)
+	}
+	if c.registry != nil && !c.cfg.DecodeRaw {
+		fmt.Fprintf(os.Stderr, "Schema registry: %s\n", c.registry)
+	}
+	if c.cfg.Verbose && c.decoder != nil {
+		for _, diag := range c.decoder.Diagnostics() {
+			fmt.Fprintf(os.Stderr, "Warning: skipped %s\n", diag)
+		}
+		for _, topic := range c.cfg.Topics {
+			fmt.Fprintf(os.Stderr, "Topic %s: %s\n", topic, c.decoder.TypeForTopic(topic))
+		}
+	}
+	if c.keyMatcher != nil {
+		fmt.Fprintf(os.Stderr, "Filtering by %s\n", c.keyMatcher)
+	}
+	if c.cfg.Filter != "" {
+		fmt.Fprintf(os.Stderr, "Filtering by expression: %s\n", c.cfg.Filter)
 	}
 	if c.cfg.Count > 0 {
 		fmt.Fprintf(os.Stderr, "Will consume %d messages\n", c.cfg.Count)
 	}
-	if c.cfg.Follow {
+	if c.cfg.Commit != CommitNone {
+		fmt.Fprintf(os.Stderr, "Committing offsets: %s\n", c.cfg.Commit)
+		defer func() {
+			if err := c.commits.flush(c.client); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to commit offsets: %v\n", err)
+			}
+		}()
+	}
+	if c.cfg.Until != "" {
+		if err := c.resolveEndOffsets(ctx); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Consuming until %s\n", c.cfg.Until)
+		if c.ends.finished() {
+			fmt.Fprintf(os.Stderr, "No messages in range\n")
+			return nil
+		}
+	} else if c.cfg.Follow {
 		fmt.Fprintf(os.Stderr, "Following topic (press Ctrl+C to stop)...\n")
 	}
 	fmt.Fprintf(os.Stderr, "Waiting for messages...\n\n")
 
 	messageCount := 0
+	if c.cfg.Verbose && c.decoder != nil {
+		// Unknown fields usually mean the producer is on a newer schema.
+		defer func() {
+			fmt.Fprintf(os.Stderr, "Messages with unknown fields: %d of %d\n", c.decoder.UnknownMessageCount(), messageCount)
+		}()
+	}
 	for {
 		select {
 		case <-ctx.Done():
@@ -140,42 +408,74 @@ func (c *Consumer) Run(ctx context.Context) error {
 			continue
 		}
 
+		var handled []*kgo.Record
 		fetches.EachPartition(func(p kgo.FetchTopicPartition) {
 			for _, record := range p.Records {
-				if c.cfg.KeyFilter != "" && string(record.Key) != c.cfg.KeyFilter {
+				if c.cfg.Count > 0 && messageCount >= c.cfg.Count {
+					return
+				}
+				if c.ends != nil && !c.ends.inRange(record) {
+					continue
+				}
+				// Transaction markers are only fetched to track --until.
+				if record.Attrs.IsControl() {
+					continue
+				}
+				handled = append(handled, record)
+				// Keys are matched before decoding so that skipped records
+				// cost next to nothing.
+				if c.keyMatcher != nil && !c.keyMatcher.match(record.Key) {
 					continue
 				}
 
-				decoded, msgType, err := c.decoder.Decode(record.Value)
+				decoded, msgType, confidence, err := c.decode(record)
+				if errors.Is(err, decoder.ErrFiltered) {
+					continue
+				}
+				key, keyValue := c.key(record)
 				if err != nil {
 					if c.cfg.Verbose {
 						fmt.Fprintf(os.Stderr, "Failed to decode message at offset %d: %v\n", record.Offset, err)
 					}
+					// A record that cannot be decoded cannot match the filter.
+					if c.cfg.Filter != "" {
+						continue
+					}
 					output := formatter.Message{
-						Topic:     record.Topic,
-						Partition: record.Partition,
-						Offset:    record.Offset,
-						Key:       string(record.Key),
-						Timestamp: record.Timestamp,
-						Error:     err.Error(),
-						RawValue:  record.Value,
+						Topic:      record.Topic,
+						Partition:  record.Partition,
+						Offset:     record.Offset,
+						Key:        key,
+						KeyValue:   keyValue,
+						Timestamp:  record.Timestamp,
+						Error:      err.Error(),
+						RawValue:   record.Value,
+						Headers:    c.headers(record),
+						Protoscope: c.protoscope(record.Value),
+					}
+					// Show the schemaless structure so the payload can still be
+					// inspected when the descriptor does not match.
+					if fields, rawErr := decoder.DecodeRaw(unframed(record.Value)); rawErr == nil {
+						output.Value = fields
 					}
 					if err := c.formatter.Format(output); err != nil {
 						fmt.Fprintf(os.Stderr, "Failed to format output: %v\n", err)
 					}
 				} else {
-					var value any
-					if err := json.Unmarshal(decoded, &value); err != nil {
-						value = string(decoded)
-					}
+					value := parseValue(decoded)
 					output := formatter.Message{
 						Topic:       record.Topic,
 						Partition:   record.Partition,
 						Offset:      record.Offset,
-						Key:         string(record.Key),
+						Key:         key,
+						KeyValue:    keyValue,
 						Timestamp:   record.Timestamp,
 						MessageType: msgType,
+						Confidence:  confidence,
 						Value:       value,
+						RawValue:    record.Value,
+						Headers:     c.headers(record),
+						Protoscope:  c.protoscope(record.Value),
 					}
 					if err := c.formatter.Format(output); err != nil {
 						fmt.Fprintf(os.Stderr, "Failed to format output: %v\n", err)
@@ -183,15 +483,27 @@ func (c *Consumer) Run(ctx context.Context) error {
 				}
 
 				messageCount++
-				if c.cfg.Count > 0 && messageCount >= c.cfg.Count {
-					return
+			}
+			if c.ends != nil && len(p.Records) > 0 && p.Err == nil {
+				next := p.Records[len(p.Records)-1].Offset + 1
+				if c.ends.reach(p.Topic, p.Partition, next, p.HighWatermark) {
+					c.client.PauseFetchPartitions(map[string][]int32{p.Topic: {p.Partition}})
 				}
 			}
 		})
+		if err := c.commits.commit(c.client, handled); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to commit offsets: %v\n", err)
+		}
 
 		if c.cfg.Count > 0 && messageCount >= c.cfg.Count {
 			break
 		}
+		if c.ends != nil {
+			if c.ends.finished() {
+				break
+			}
+			continue
+		}
 		if !c.cfg.Follow {
 			if fetches.NumRecords() == 0 {
 				break
@@ -200,3 +512,286 @@ func (c *Consumer) Run(ctx context.Context) error {
 	}
 	return nil
 }
+
+// decode decodes a record's value using the message type named by the type
+// header if present, and otherwise the type mapped to the record's topic.
+// Confluent-framed values are unwrapped first and, with a schema registry,
+// decoded with their registered schema. The returned confidence is non-zero
+// only for inferred types.
+func (c *Consumer) decode(record *kgo.Record) ([]byte, string, float64, error) {
+	if c.cfg.DecodeRaw {
+		fields, err := decoder.DecodeRaw(unframed(record.Value))
+		if err != nil {
+			return nil, "", 0, err
+		}
+		decoded, err := json.Marshal(fields)
+		return decoded, "", 0, err
+	}
+
+	typeName := ""
+	if c.cfg.TypeHeader != "" {
+		for _, h := range record.Headers {
+			if strings.EqualFold(h.Key, c.cfg.TypeHeader) {
+				typeName = decoder.ParseTypeHeader(string(h.Value))
+				break
+			}
+		}
+		// A header naming a type that is not loaded falls back to the
+		// topic's type like a missing header.
+		if typeName != "" && c.decoder.CheckType(typeName) != nil {
+			if c.cfg.Verbose {
+				fmt.Fprintf(os.Stderr, "Unknown type %s in header %s at %s/%d@%d\n", typeName, c.cfg.TypeHeader, record.Topic, record.Partition, record.Offset)
+			}
+			typeName = ""
+		}
+	}
+	if typeName == "" {
+		typeName = c.decoder.TypeForTopic(record.Topic)
+	}
+
+	payload := record.Value
+	if frame, ok := decoder.ParseConfluentFrame(record.Value); ok {
+		// Without a registry an inferred type is matched against the bare
+		// payload, since the frame's message indexes need a known schema.
+		if c.registry != nil || typeName != decoder.AutoType {
+			decoded, msgType, err := c.decoder.DecodeConfluent(frame, typeName)
+			return decoded, msgType, 0, err
+		}
+		payload = frame.Payload
+	}
+
+	switch typeName {
+	case "":
+		return nil, "", 0, fmt.Errorf("no message type for topic %s", record.Topic)
+	case decoder.AutoType:
+		decoded, msgType, confidence, err := c.decoder.DecodeAuto(payload)
+		if err == nil && c.cfg.Verbose {
+			fmt.Fprintf(os.Stderr, "Inferred %s at %s/%d@%d (confidence %.0f%%)\n",
+				msgType, record.Topic, record.Partition, record.Offset, confidence*100)
+		}
+		return decoded, msgType, confidence, err
+	default:
+		decoded, msgType, err := c.decoder.DecodeType(payload, typeName)
+		return decoded, msgType, 0, err
+	}
+}
+
+// key renders the record's key in the configured format. Keys that do not
+// fit it are shown as hex.
+func (c *Consumer) key(record *kgo.Record) (string, any) {
+	text, value, err := c.keys.decode(record.Key)
+	if err != nil {
+		if c.cfg.Verbose {
+			fmt.Fprintf(os.Stderr, "Failed to decode key at offset %d: %v\n", record.Offset, err)
+		}
+		return hex.EncodeToString(record.Key), nil
+	}
+	return text, value
+}
+
+// headers converts the record's headers for output. Values of headers with a
+// configured message type are decoded; others are shown as text when they
+// are valid UTF-8.
+func (c *Consumer) headers(record *kgo.Record) []formatter.Header {
+	if len(record.Headers) == 0 {
+		return nil
+	}
+	headers := make([]formatter.Header, len(record.Headers))
+	for i, h := range record.Headers {
+		headers[i] = formatter.Header{Key: h.Key, RawValue: h.Value}
+		if typeName, ok := c.headerTypes[strings.ToLower(h.Key)]; ok {
+			decoded, err := c.decoder.DecodeEmbedded(h.Value, typeName)
+			if err == nil {
+				headers[i].Value = parseValue(decoded)
+				continue
+			}
+			if c.cfg.Verbose {
+				fmt.Fprintf(os.Stderr, "Failed to decode header %s at offset %d: %v\n", h.Key, record.Offset, err)
+			}
+		}
+		if utf8.Valid(h.Value) {
+			headers[i].Value = string(h.Value)
+		}
+	}
+	return headers
+}
+
+// protoscope renders the wire format of a record's value, without any
+// Confluent framing, when the output format is protoscope.
+func (c *Consumer) protoscope(value []byte) string {
+	if c.cfg.OutputFormat != "protoscope" {
+		return ""
+	}
+	value = unframed(value)
+	fields, err := decoder.DecodeRaw(value)
+	if err != nil {
+		return fmt.Sprintf("# invalid wire format: %v\n`%s`\n", err, hex.EncodeToString(value))
+	}
+	return decoder.Protoscope(fields)
+}
+
+// parseValue parses decoded JSON for the formatter. Numbers are kept as
+// written so that 64-bit integers do not lose precision.
+func parseValue(decoded []byte) any {
+	dec := json.NewDecoder(bytes.NewReader(decoded))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return string(decoded)
+	}
+	return value
+}
+
+// unframed strips the Confluent wire-format framing from value, if present.
+func unframed(value []byte) []byte {
+	if frame, ok := decoder.ParseConfluentFrame(value); ok {
+		return frame.Payload
+	}
+	return value
+}
+
+// resolveEndOffsets snapshots the end offset of every partition for --until.
+// Start offsets are resolved as well so that partitions with an empty range
+// do not keep the consumer waiting.
+func (c *Consumer) resolveEndOffsets(ctx context.Context) error {
+	topics, err := c.resolveTopics(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.ends = newEndOffsets()
+	for _, topic := range topics {
+		partitions := c.assignedPartitions()
+		if partitions == nil {
+			partitions, err = listPartitions(ctx, c.client, topic)
+			if err != nil {
+				return err
+			}
+		}
+
+		var ends map[int32]int64
+		switch c.until.kind {
+		case untilOffset:
+			var latest map[int32]int64
+			latest, err = listOffsets(ctx, c.client, topic, partitions, listOffsetsLatest)
+			ends = offsetEnds(c.until.value, latest)
+		case untilTimestamp:
+			ends, err = listOffsets(ctx, c.client, topic, partitions, c.until.value)
+		case untilNow:
+			ends, err = listOffsets(ctx, c.client, topic, partitions, listOffsetsLatest)
+		}
+		if err != nil {
+			return err
+		}
+
+		starts, err := c.resolveStartOffsets(ctx, topic, partitions)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range partitions {
+			c.ends.set(topic, p, starts[p], ends[p])
+			if c.cfg.Verbose {
+				fmt.Fprintf(os.Stderr, "%s/%d: start %d, end %d\n", topic, p, starts[p], ends[p])
+			}
+		}
+	}
+	return nil
+}
+
+// resolveStartOffsets returns the concrete start offset of each partition, or
+// -1 where it depends on the group's stored offsets.
+func (c *Consumer) resolveStartOffsets(ctx context.Context, topic string, partitions []int32) (map[int32]int64, error) {
+	starts := make(map[int32]int64, len(partitions))
+	byTimestamp := make(map[int64][]int32)
+	for _, p := range partitions {
+		start := c.start
+		if s, ok := c.partitionStarts[p]; ok {
+			start = s
+		}
+		switch {
+		case start.stored:
+			starts[p] = -1
+		case start.exact:
+			starts[p] = start.at
+		default:
+			byTimestamp[start.listTimestamp] = append(byTimestamp[start.listTimestamp], p)
+		}
+	}
+
+	for timestamp, ps := range byTimestamp {
+		offsets, err := listOffsets(ctx, c.client, topic, ps, timestamp)
+		if err != nil {
+			return nil, err
+		}
+		for p, o := range offsets {
+			starts[p] = o
+		}
+	}
+	return starts, nil
+}
+
+// resolveTopics returns the consumed topics, listing the cluster's topics
+// when consuming by regex.
+func (c *Consumer) resolveTopics(ctx context.Context) ([]string, error) {
+	if c.topicRegex == nil {
+		return c.cfg.Topics, nil
+	}
+	all, err := listTopics(ctx, c.client)
+	if err != nil {
+		return nil, err
+	}
+	var topics []string
+	for _, topic := range all {
+		if c.topicRegex.MatchString(topic) {
+			topics = append(topics, topic)
+		}
+	}
+	if len(topics) == 0 {
+		return nil, fmt.Errorf("no topics match %q", c.cfg.TopicRegex)
+	}
+	return topics, nil
+}
+
+// validatePartitions checks that every explicitly selected partition exists,
+// since consuming a missing partition would otherwise wait forever.
+func (c *Consumer) validatePartitions(ctx context.Context) error {
+	for _, topic := range c.cfg.Topics {
+		existing, err := listPartitions(ctx, c.client, topic)
+		if err != nil {
+			return err
+		}
+		for _, p := range c.assignedPartitions() {
+			i := sort.Search(len(existing), func(i int) bool { return existing[i] >= p })
+			if i == len(existing) || existing[i] != p {
+				return fmt.Errorf("partition %d does not exist in topic %s (partitions: %v)", p, topic, existing)
+			}
+		}
+	}
+	return nil
+}
+
+// describeTopics renders the consumed topics for status output.
+func (c *Consumer) describeTopics() string {
+	if c.topicRegex != nil {
+		return fmt.Sprintf("topics matching '%s'", c.cfg.TopicRegex)
+	}
+	if len(c.cfg.Topics) == 1 {
+		return fmt.Sprintf("topic '%s'", c.cfg.Topics[0])
+	}
+	return fmt.Sprintf("topics %v", c.cfg.Topics)
+}
+
+// assignedPartitions returns the sorted explicitly selected partitions, or nil
+// when consuming all partitions of the topic.
+func (c *Consumer) assignedPartitions() []int32 {
+	if c.partitionStarts == nil {
+		return nil
+	}
+	partitions := make([]int32, 0, len(c.partitionStarts))
+	for p := range c.partitionStarts {
+		partitions = append(partitions, p)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+	return partitions
+}
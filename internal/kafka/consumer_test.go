@@ -0,0 +1,194 @@
+package kafka
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/HurSungYun/buf-kcat/internal/decoder"
+	"github.com/HurSungYun/buf-kcat/internal/formatter"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const testDescriptorSet = "../../test/example/schema.desc"
+
+func TestNewConsumerValidation(t *testing.T) {
+	base := func() ConsumerConfig {
+		return ConsumerConfig{
+			Brokers:      []string{"localhost:9092"},
+			Topics:       []string{"events"},
+			ProtoPath:    testDescriptorSet,
+			MessageType:  "events.UserEvent",
+			OutputFormat: "json",
+			Offset:       "end",
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(*ConsumerConfig)
+		wantErr string
+	}{
+		{"defaults", func(*ConsumerConfig) {}, ""},
+		{"multiple topics", func(c *ConsumerConfig) { c.Topics = []string{"orders", "payments"} }, ""},
+		{"topic regex", func(c *ConsumerConfig) { c.Topics = nil; c.TopicRegex = `events\..*` }, ""},
+		{"partitions", func(c *ConsumerConfig) { c.Partitions = []int32{3} }, ""},
+		{"per-partition offsets", func(c *ConsumerConfig) { c.Offset = "3:12345,5:900" }, ""},
+		{"group with stored offsets", func(c *ConsumerConfig) { c.Group = "debug"; c.Offset = "stored" }, ""},
+		{"group with commit", func(c *ConsumerConfig) { c.Group = "debug"; c.Commit = CommitPeriodic }, ""},
+		{"type map without message type", func(c *ConsumerConfig) {
+			c.MessageType = ""
+			c.TypeMap = map[string]string{"events": "events.UserEvent"}
+		}, ""},
+		{"type header without message type", func(c *ConsumerConfig) { c.MessageType = ""; c.TypeHeader = "proto-type" }, ""},
+		{"decode raw without descriptors", func(c *ConsumerConfig) { c.MessageType = ""; c.ProtoPath = "missing/buf.yaml"; c.DecodeRaw = true }, ""},
+		{"schema registry without descriptors", func(c *ConsumerConfig) {
+			c.MessageType = ""
+			c.ProtoPath = ""
+			c.SchemaRegistry = "http://localhost:8081"
+		}, ""},
+		{"no topic", func(c *ConsumerConfig) { c.Topics = nil }, "topic is required"},
+		{"no message type", func(c *ConsumerConfig) { c.MessageType = "" }, "message type is required"},
+		{"type map with unknown type", func(c *ConsumerConfig) { c.TypeMap = map[string]string{"events": "events.Missing"} }, "invalid type map"},
+		{"topic and regex", func(c *ConsumerConfig) { c.TopicRegex = "events" }, "cannot be combined with --topic-regex"},
+		{"invalid regex", func(c *ConsumerConfig) { c.Topics = nil; c.TopicRegex = "(" }, "invalid topic regex"},
+		{"regex with partitions", func(c *ConsumerConfig) { c.Topics = nil; c.TopicRegex = "events"; c.Partitions = []int32{0} }, "--topic-regex cannot be combined"},
+		{"stored without group", func(c *ConsumerConfig) { c.Offset = "stored" }, "require a consumer group"},
+		{"stored with partitions", func(c *ConsumerConfig) { c.Offset = "3:stored" }, "require a consumer group"},
+		{"partitions and per-partition offsets", func(c *ConsumerConfig) { c.Partitions = []int32{3}; c.Offset = "3:1" }, "--partition cannot be combined"},
+		{"group with partitions", func(c *ConsumerConfig) { c.Group = "debug"; c.Partitions = []int32{3} }, "--group cannot be combined"},
+		{"commit without group", func(c *ConsumerConfig) { c.Commit = CommitAfterPrint }, "requires a consumer group"},
+		{"unknown commit mode", func(c *ConsumerConfig) { c.Group = "debug"; c.Commit = "always" }, "invalid commit mode"},
+		{"invalid until", func(c *ConsumerConfig) { c.Until = "later" }, "invalid until"},
+		{"until with group", func(c *ConsumerConfig) { c.Group = "debug"; c.Until = "now" }, "--until cannot be combined with --group"},
+		{"header type", func(c *ConsumerConfig) { c.HeaderTypes = map[string]string{"trace": "events.EventMetadata"} }, ""},
+		{"header type with unknown type", func(c *ConsumerConfig) { c.HeaderTypes = map[string]string{"trace": "events.Missing"} }, "invalid header type for trace"},
+		{"header type with decode raw", func(c *ConsumerConfig) {
+			c.DecodeRaw = true
+			c.HeaderTypes = map[string]string{"trace": "events.EventMetadata"}
+		}, "cannot be combined with --decode-raw"},
+		{"key type", func(c *ConsumerConfig) { c.KeyType = "events.EventMetadata" }, ""},
+		{"invalid key format", func(c *ConsumerConfig) { c.KeyFormat = "uuid" }, "invalid key format"},
+		{"key type with decode raw", func(c *ConsumerConfig) { c.DecodeRaw = true; c.KeyType = "events.EventMetadata" }, "--key-type requires descriptors"},
+		{"filter", func(c *ConsumerConfig) { c.Filter = `event_type == "login" && "ip" in metadata` }, ""},
+		{"filter with unknown field", func(c *ConsumerConfig) { c.Filter = `total_amount > 100.0` }, "invalid filter for events.UserEvent"},
+		{"filter with type map", func(c *ConsumerConfig) {
+			c.TypeMap = map[string]string{"orders": "events.OrderEvent"}
+			c.Filter = `user_id == "42"`
+		}, ""},
+		{"filter with decode raw", func(c *ConsumerConfig) { c.DecodeRaw = true; c.Filter = `user_id == "42"` }, "--filter cannot be combined"},
+		{"invalid schema registry", func(c *ConsumerConfig) { c.SchemaRegistry = "localhost:8081" }, "invalid schema registry URL"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base()
+			tt.modify(&cfg)
+			c, err := NewConsumer(cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("NewConsumer failed: %v", err)
+				}
+				c.Close()
+				return
+			}
+			if err == nil {
+				c.Close()
+				t.Fatalf("NewConsumer succeeded, want error containing %q", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewConsumer error = %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	dec, err := decoder.NewDecoder(testDescriptorSet, "events.UserEvent")
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	c := &Consumer{
+		decoder:     dec,
+		headerTypes: map[string]string{"user": "events.UserEvent", "broken": "events.UserEvent"},
+	}
+
+	var user []byte
+	user = protowire.AppendTag(user, 1, protowire.BytesType)
+	user = protowire.AppendString(user, "u-1")
+	record := &kgo.Record{Headers: []kgo.RecordHeader{
+		{Key: "trace-id", Value: []byte("abc")},
+		{Key: "User", Value: user},
+		{Key: "binary", Value: []byte{0xff, 0x00}},
+		{Key: "broken", Value: []byte{0xff}},
+	}}
+
+	got := c.headers(record)
+	want := []formatter.Header{
+		{Key: "trace-id", Value: "abc", RawValue: []byte("abc")},
+		{Key: "User", Value: map[string]any{"user_id": "u-1"}, RawValue: user},
+		{Key: "binary", RawValue: []byte{0xff, 0x00}},
+		{Key: "broken", RawValue: []byte{0xff}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("headers = %+v, want %+v", got, want)
+	}
+
+	if headers := c.headers(&kgo.Record{}); headers != nil {
+		t.Errorf("headers of a record without headers = %+v, want nil", headers)
+	}
+}
+
+func TestProtoscope(t *testing.T) {
+	c := &Consumer{cfg: ConsumerConfig{OutputFormat: "protoscope"}}
+	payload := []byte{0x08, 0x96, 0x01}
+	framed := append([]byte{0x00, 0x00, 0x00, 0x00, 0x2a, 0x00}, payload...)
+
+	for _, value := range [][]byte{payload, framed} {
+		if got, want := c.protoscope(value), "1: 150\n"; got != want {
+			t.Errorf("protoscope(%x) = %q, want %q", value, got, want)
+		}
+	}
+	if got := c.protoscope([]byte{0x0a, 0x05}); !strings.HasPrefix(got, "# invalid wire format") {
+		t.Errorf("protoscope of a truncated value = %q, want an invalid wire format comment", got)
+	}
+
+	c.cfg.OutputFormat = "json"
+	if got := c.protoscope(payload); got != "" {
+		t.Errorf("protoscope with json output = %q, want empty", got)
+	}
+}
+
+func TestDecodeTypeHeaderFallback(t *testing.T) {
+	dec, err := decoder.NewDecoder(testDescriptorSet, "events.UserEvent")
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	c := &Consumer{decoder: dec, cfg: ConsumerConfig{TypeHeader: "content-type"}}
+
+	var user []byte
+	user = protowire.AppendTag(user, 1, protowire.BytesType)
+	user = protowire.AppendString(user, "u-1")
+
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"application/x-protobuf; messageType=events.EventMetadata", "events.EventMetadata"},
+		{"application/x-protobuf", "events.UserEvent"},
+		{"application/json", "events.UserEvent"},
+		{"events.Missing", "events.UserEvent"},
+	}
+	for _, tt := range tests {
+		record := &kgo.Record{
+			Topic:   "events",
+			Value:   user,
+			Headers: []kgo.RecordHeader{{Key: "Content-Type", Value: []byte(tt.header)}},
+		}
+		_, msgType, _, err := c.decode(record)
+		if err != nil || msgType != tt.want {
+			t.Errorf("decode with header %q = %s, %v, want %s", tt.header, msgType, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/HurSungYun/buf-kcat/internal/decoder"
+)
+
+// Key formats for ConsumerConfig.KeyFormat and ProducerConfig.KeyFormat.
+const (
+	KeyFormatString = "string"
+	KeyFormatHex    = "hex"
+	KeyFormatBase64 = "base64"
+	KeyFormatInt64  = "int64-big-endian"
+)
+
+// keyCodec converts record keys between their wire form and text, either in
+// one of the key formats or as a protobuf message of typeName.
+type keyCodec struct {
+	format   string
+	typeName string
+	decoder  *decoder.Decoder
+}
+
+// newKeyCodec validates a key format and message type. A message type needs
+// dec and replaces the format, which must then be left at its default.
+func newKeyCodec(format, typeName string, dec *decoder.Decoder) (keyCodec, error) {
+	if typeName != "" {
+		if format != "" && format != KeyFormatString {
+			return keyCodec{}, fmt.Errorf("--key-type cannot be combined with --key-format %s", format)
+		}
+		if dec == nil {
+			return keyCodec{}, fmt.Errorf("--key-type requires descriptors and cannot be combined with --decode-raw")
+		}
+		if err := dec.CheckType(typeName); err != nil {
+			return keyCodec{}, fmt.Errorf("invalid key type: %w", err)
+		}
+		return keyCodec{typeName: typeName, decoder: dec}, nil
+	}
+
+	switch format {
+	case "":
+		format = KeyFormatString
+	case KeyFormatString, KeyFormatHex, KeyFormatBase64, KeyFormatInt64:
+	default:
+		return keyCodec{}, fmt.Errorf("invalid key format %q: expected string, hex, base64, or int64-big-endian", format)
+	}
+	return keyCodec{format: format}, nil
+}
+
+// decode renders key as text, along with a structured value for JSON output
+// when the key is a number or a message. Keys that do not fit the format are
+// reported as errors.
+func (k keyCodec) decode(key []byte) (string, any, error) {
+	if key == nil {
+		return "", nil, nil
+	}
+	if k.typeName != "" {
+		decoded, err := k.decoder.DecodeEmbedded(key, k.typeName)
+		if err != nil {
+			return "", nil, err
+		}
+		value := parseValue(decoded)
+		text, err := compactJSON(decoded)
+		if err != nil {
+			return "", nil, err
+		}
+		return text, value, nil
+	}
+
+	switch k.format {
+	case KeyFormatHex:
+		return hex.EncodeToString(key), nil, nil
+	case KeyFormatBase64:
+		return base64.StdEncoding.EncodeToString(key), nil, nil
+	case KeyFormatInt64:
+		if len(key) != 8 {
+			return "", nil, fmt.Errorf("key of %d bytes is not a big-endian int64", len(key))
+		}
+		n := int64(binary.BigEndian.Uint64(key))
+		return strconv.FormatInt(n, 10), n, nil
+	default:
+		return string(key), nil, nil
+	}
+}
+
+// encode converts a key given on the command line to its wire form. Message
+// keys are given as JSON.
+func (k keyCodec) encode(text string) ([]byte, error) {
+	if k.typeName != "" {
+		return encodeMessage(k.decoder, k.typeName, []byte(text))
+	}
+
+	switch k.format {
+	case KeyFormatHex:
+		key, err := hex.DecodeString(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex key: %w", err)
+		}
+		return key, nil
+	case KeyFormatBase64:
+		key, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 key: %w", err)
+		}
+		return key, nil
+	case KeyFormatInt64:
+		n, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid int64 key: %w", err)
+		}
+		return binary.BigEndian.AppendUint64(nil, uint64(n)), nil
+	default:
+		return []byte(text), nil
+	}
+}
+
+func compactJSON(data []byte) (string, error) {
+	var b bytes.Buffer
+	if err := json.Compact(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
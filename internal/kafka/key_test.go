@@ -0,0 +1,103 @@
+package kafka
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/HurSungYun/buf-kcat/internal/decoder"
+)
+
+func TestKeyCodec(t *testing.T) {
+	dec, err := decoder.NewDecoder(testDescriptorSet, "events.OrderEvent")
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		format    string
+		typeName  string
+		text      string
+		wire      []byte
+		wantText  string
+		wantValue any
+	}{
+		{"default", "", "", "order-1", []byte("order-1"), "order-1", nil},
+		{"string", KeyFormatString, "", "order-1", []byte("order-1"), "order-1", nil},
+		{"hex", KeyFormatHex, "", "00ff10", []byte{0x00, 0xff, 0x10}, "00ff10", nil},
+		{"base64", KeyFormatBase64, "", "AP8Q", []byte{0x00, 0xff, 0x10}, "AP8Q", nil},
+		{"int64", KeyFormatInt64, "", "-2", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe}, "-2", int64(-2)},
+		{"message", "", "events.OrderItem", `{"product_id": "p-1", "quantity": 2}`,
+			[]byte{0x0a, 0x03, 'p', '-', '1', 0x10, 0x02},
+			`{"product_id":"p-1","quantity":2}`,
+			map[string]any{"product_id": "p-1", "quantity": json.Number("2")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := newKeyCodec(tt.format, tt.typeName, dec)
+			if err != nil {
+				t.Fatalf("newKeyCodec failed: %v", err)
+			}
+
+			wire, err := keys.encode(tt.text)
+			if err != nil {
+				t.Fatalf("encode failed: %v", err)
+			}
+			if !reflect.DeepEqual(wire, tt.wire) {
+				t.Errorf("encode(%q) = %x, want %x", tt.text, wire, tt.wire)
+			}
+
+			text, value, err := keys.decode(tt.wire)
+			if err != nil {
+				t.Fatalf("decode failed: %v", err)
+			}
+			if text != tt.wantText {
+				t.Errorf("decode text = %q, want %q", text, tt.wantText)
+			}
+			if !reflect.DeepEqual(value, tt.wantValue) {
+				t.Errorf("decode value = %#v, want %#v", value, tt.wantValue)
+			}
+		})
+	}
+
+	// Unknown fields in keys do not count as values with unknown fields.
+	keys, _ := newKeyCodec("", "events.OrderItem", dec)
+	unknownKey := []byte{0x0a, 0x03, 'p', '-', '1', 0xc8, 0x06, 0x01}
+	if _, _, err := keys.decode(unknownKey); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if got := dec.UnknownMessageCount(); got != 0 {
+		t.Errorf("UnknownMessageCount after decoding a key = %d, want 0", got)
+	}
+
+	keys, _ = newKeyCodec(KeyFormatInt64, "", nil)
+	if _, _, err := keys.decode([]byte{1, 2}); err == nil {
+		t.Error("int64 decode accepted a 2-byte key")
+	}
+	if text, _, err := keys.decode(nil); err != nil || text != "" {
+		t.Errorf("decode of a null key = %q, %v, want empty", text, err)
+	}
+
+	errTests := []struct {
+		name     string
+		format   string
+		typeName string
+		dec      *decoder.Decoder
+		wantErr  string
+	}{
+		{"unknown format", "protobuf", "", dec, "invalid key format"},
+		{"type with format", KeyFormatHex, "events.OrderItem", dec, "cannot be combined with --key-format"},
+		{"unknown type", "", "events.Missing", dec, "invalid key type"},
+		{"type without descriptors", "", "events.OrderItem", nil, "requires descriptors"},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newKeyCodec(tt.format, tt.typeName, tt.dec)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("newKeyCodec error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package kafka
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// keyMatcher selects records by their raw key before anything is decoded.
+// A record must satisfy every configured criterion.
+type keyMatcher struct {
+	// keys holds the accepted exact keys, or nil to accept any key.
+	keys   map[string]bool
+	prefix []byte
+	regex  *regexp.Regexp
+}
+
+// newKeyMatcher builds the key filter of cfg, or returns nil if cfg does not
+// filter by key.
+func newKeyMatcher(cfg ConsumerConfig) (*keyMatcher, error) {
+	if cfg.KeyFilter == "" && cfg.KeyFile == "" && cfg.KeyPrefix == "" && cfg.KeyRegex == "" {
+		return nil, nil
+	}
+
+	m := &keyMatcher{}
+	if cfg.KeyFilter != "" || cfg.KeyFile != "" {
+		m.keys = make(map[string]bool)
+	}
+	if cfg.KeyFilter != "" {
+		key, err := parseKeyLiteral(cfg.KeyFilter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key: %w", err)
+		}
+		m.keys[string(key)] = true
+	}
+	if cfg.KeyFile != "" {
+		keys, err := loadKeyFile(cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			m.keys[string(key)] = true
+		}
+	}
+	if cfg.KeyPrefix != "" {
+		prefix, err := parseKeyLiteral(cfg.KeyPrefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key prefix: %w", err)
+		}
+		m.prefix = prefix
+	}
+	if cfg.KeyRegex != "" {
+		re, err := regexp.Compile(cfg.KeyRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key regex: %w", err)
+		}
+		m.regex = re
+	}
+	return m, nil
+}
+
+// match reports whether a record with key passes the filter.
+func (m *keyMatcher) match(key []byte) bool {
+	if m.keys != nil && !m.keys[string(key)] {
+		return false
+	}
+	if m.prefix != nil && !bytes.HasPrefix(key, m.prefix) {
+		return false
+	}
+	if m.regex != nil && !m.regex.Match(key) {
+		return false
+	}
+	return true
+}
+
+// String describes the filter for status output.
+func (m *keyMatcher) String() string {
+	var parts []string
+	if len(m.keys) == 1 {
+		for key := range m.keys {
+			parts = append(parts, fmt.Sprintf("key %q", key))
+		}
+	} else if m.keys != nil {
+		parts = append(parts, fmt.Sprintf("%d keys", len(m.keys)))
+	}
+	if m.prefix != nil {
+		parts = append(parts, fmt.Sprintf("prefix %q", m.prefix))
+	}
+	if m.regex != nil {
+		parts = append(parts, fmt.Sprintf("regex %s", m.regex))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// parseKeyLiteral converts a key given on the command line to bytes. Binary
+// keys are written as hex:00ff or base64:AP8=; anything else is taken as is.
+func parseKeyLiteral(s string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(s, "hex:"):
+		key, err := hex.DecodeString(strings.TrimPrefix(s, "hex:"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex literal %q: %w", s, err)
+		}
+		return key, nil
+	case strings.HasPrefix(s, "base64:"):
+		key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, "base64:"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 literal %q: %w", s, err)
+		}
+		return key, nil
+	default:
+		return []byte(s), nil
+	}
+}
+
+// loadKeyFile reads a file of keys, one key literal per line. Blank lines and
+// lines starting with # are skipped.
+func loadKeyFile(path string) ([][]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open key file: %w", err)
+	}
+	defer file.Close()
+
+	var keys [][]byte
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, err := parseKeyLiteral(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+		keys = append(keys, key)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("key file %s contains no keys", path)
+	}
+	return keys, nil
+}
@@ -0,0 +1,72 @@
+package kafka
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestKeyMatcher(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "keys.txt")
+	content := "# orders to inspect\norder-1\n\nhex:00ff\nbase64:AQI=\n"
+	if err := os.WriteFile(keyFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		cfg    ConsumerConfig
+		match  []string
+		reject []string
+	}{
+		{"exact", ConsumerConfig{KeyFilter: "order-1"}, []string{"order-1"}, []string{"order-10", ""}},
+		{"hex literal", ConsumerConfig{KeyFilter: "hex:00ff"}, []string{"\x00\xff"}, []string{"hex:00ff"}},
+		{"key file", ConsumerConfig{KeyFile: keyFile}, []string{"order-1", "\x00\xff", "\x01\x02"}, []string{"# orders to inspect", ""}},
+		{"prefix", ConsumerConfig{KeyPrefix: "tenant-42/"}, []string{"tenant-42/order-1"}, []string{"tenant-4/order-1"}},
+		{"binary prefix", ConsumerConfig{KeyPrefix: "base64:AQ=="}, []string{"\x01\x02"}, []string{"\x02\x01"}},
+		{"regex", ConsumerConfig{KeyRegex: `^order-[0-9]+$`}, []string{"order-1", "order-42"}, []string{"order-x", "my-order-1"}},
+		{"all criteria", ConsumerConfig{KeyFile: keyFile, KeyRegex: "^order"}, []string{"order-1"}, []string{"\x00\xff"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := newKeyMatcher(tt.cfg)
+			if err != nil {
+				t.Fatalf("newKeyMatcher failed: %v", err)
+			}
+			for _, key := range tt.match {
+				if !m.match([]byte(key)) {
+					t.Errorf("match(%q) = false, want true", key)
+				}
+			}
+			for _, key := range tt.reject {
+				if m.match([]byte(key)) {
+					t.Errorf("match(%q) = true, want false", key)
+				}
+			}
+		})
+	}
+
+	if m, err := newKeyMatcher(ConsumerConfig{}); m != nil || err != nil {
+		t.Errorf("newKeyMatcher without key filters = %v, %v, want nil", m, err)
+	}
+
+	errTests := []struct {
+		name    string
+		cfg     ConsumerConfig
+		wantErr string
+	}{
+		{"invalid hex", ConsumerConfig{KeyFilter: "hex:0g"}, "invalid hex literal"},
+		{"invalid base64 prefix", ConsumerConfig{KeyPrefix: "base64:!"}, "invalid key prefix"},
+		{"invalid regex", ConsumerConfig{KeyRegex: "("}, "invalid key regex"},
+		{"missing key file", ConsumerConfig{KeyFile: filepath.Join(t.TempDir(), "missing")}, "failed to open key file"},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newKeyMatcher(tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("newKeyMatcher error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
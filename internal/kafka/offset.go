@@ -0,0 +1,213 @@
+package kafka
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// startOffset is a parsed --offset value.
+type startOffset struct {
+	// offset is where each partition starts consuming.
+	offset kgo.Offset
+	// stored reports whether the group's committed offsets should be used.
+	stored bool
+	// exact reports whether offset is an absolute position, held in at.
+	exact bool
+	at    int64
+	// listTimestamp is the ListOffsets timestamp that resolves offset to a
+	// concrete position. It is meaningless when stored or exact is set.
+	listTimestamp int64
+}
+
+// parseStartOffset converts an --offset value into the offset each partition
+// starts consuming from.
+func parseStartOffset(spec string, now time.Time) (startOffset, error) {
+	switch {
+	case spec == "" || spec == "end":
+		return startOffset{offset: kgo.NewOffset().AtEnd(), listTimestamp: listOffsetsLatest}, nil
+	case spec == "beginning":
+		return startOffset{offset: kgo.NewOffset().AtStart(), listTimestamp: listOffsetsEarliest}, nil
+	case spec == "stored":
+		return startOffset{offset: kgo.NewOffset(), stored: true}, nil
+	case strings.HasPrefix(spec, "timestamp:"):
+		millis, err := parseTimestamp(strings.TrimPrefix(spec, "timestamp:"), now)
+		if err != nil {
+			return startOffset{}, err
+		}
+		// AfterMilli resolves the timestamp to an offset per partition with a
+		// ListOffsets request, so every partition starts at its own first
+		// record at or after the given time.
+		return startOffset{offset: kgo.NewOffset().AfterMilli(millis), listTimestamp: millis}, nil
+	default:
+		n, err := strconv.ParseInt(spec, 10, 64)
+		if err != nil || n < 0 {
+			return startOffset{}, fmt.Errorf("invalid offset %q: expected beginning, end, stored, an absolute offset, or timestamp:<time>", spec)
+		}
+		return startOffset{offset: kgo.NewOffset().At(n), exact: true, at: n}, nil
+	}
+}
+
+// parsePartitionOffsets parses per-partition start offsets of the form
+// "3:12345,5:beginning". It returns nil if spec is not in that form, in which
+// case it applies to every partition and should go to parseStartOffset.
+func parsePartitionOffsets(spec string, now time.Time) (map[int32]startOffset, error) {
+	first, _, ok := strings.Cut(spec, ":")
+	if !ok {
+		return nil, nil
+	}
+	if _, err := strconv.ParseInt(first, 10, 32); err != nil {
+		return nil, nil
+	}
+
+	offsets := make(map[int32]startOffset)
+	for _, part := range strings.Split(spec, ",") {
+		partition, value, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid partition offset %q: expected PARTITION:OFFSET", part)
+		}
+		p, err := strconv.ParseInt(partition, 10, 32)
+		if err != nil || p < 0 {
+			return nil, fmt.Errorf("invalid partition %q in offset %q", partition, part)
+		}
+		if _, dup := offsets[int32(p)]; dup {
+			return nil, fmt.Errorf("partition %d given more than once in offset %q", p, spec)
+		}
+		start, err := parseStartOffset(value, now)
+		if err != nil {
+			return nil, err
+		}
+		offsets[int32(p)] = start
+	}
+	return offsets, nil
+}
+
+// untilKind identifies how an --until bound is resolved.
+type untilKind int
+
+const (
+	untilNone untilKind = iota
+	untilOffset
+	untilTimestamp
+	untilNow
+)
+
+// endBound is a parsed --until value.
+type endBound struct {
+	kind untilKind
+	// value is the exclusive end offset applied to every partition for
+	// untilOffset, or the timestamp in Unix milliseconds for untilTimestamp.
+	value int64
+}
+
+// parseEndBound converts an --until value into the bound at which each
+// partition stops consuming.
+func parseEndBound(spec string, now time.Time) (endBound, error) {
+	switch {
+	case spec == "":
+		return endBound{kind: untilNone}, nil
+	case spec == "now":
+		return endBound{kind: untilNow}, nil
+	case strings.HasPrefix(spec, "offset:"):
+		n, err := strconv.ParseInt(strings.TrimPrefix(spec, "offset:"), 10, 64)
+		if err != nil || n < 0 {
+			return endBound{}, fmt.Errorf("invalid end offset %q: expected a non-negative integer", spec)
+		}
+		return endBound{kind: untilOffset, value: n}, nil
+	case strings.HasPrefix(spec, "timestamp:"):
+		millis, err := parseTimestamp(strings.TrimPrefix(spec, "timestamp:"), now)
+		if err != nil {
+			return endBound{}, err
+		}
+		return endBound{kind: untilTimestamp, value: millis}, nil
+	default:
+		return endBound{}, fmt.Errorf("invalid until %q: expected now, offset:N, or timestamp:<time>", spec)
+	}
+}
+
+// parseTimestamp parses a point in time given as Unix milliseconds, an
+// RFC3339 timestamp, or a duration relative to now such as -15m.
+func parseTimestamp(s string, now time.Time) (int64, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty timestamp")
+	}
+	if millis, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return millis, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t.UnixMilli(), nil
+	}
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		d, err := time.ParseDuration(s)
+		if err == nil {
+			return now.Add(d).UnixMilli(), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid timestamp %q: expected Unix milliseconds, RFC3339, or a relative duration like -15m", s)
+}
+
+// offsetEnds returns the end offsets for --until offset:N given the high
+// watermark of every partition at startup. Ends past the log are capped at
+// its end, so that every partition stops where its log ended at startup,
+// whether or not it has records left to fetch.
+func offsetEnds(offset int64, latest map[int32]int64) map[int32]int64 {
+	ends := make(map[int32]int64, len(latest))
+	for p, hw := range latest {
+		ends[p] = min(offset, hw)
+	}
+	return ends
+}
+
+// endOffsets tracks the exclusive end offset of every consumed partition and
+// which partitions have been read up to it.
+type endOffsets struct {
+	ends      map[string]map[int32]int64
+	remaining int
+}
+
+func newEndOffsets() *endOffsets {
+	return &endOffsets{ends: make(map[string]map[int32]int64)}
+}
+
+// set records the end offset of a partition. A partition whose start is
+// already at or past its end is considered exhausted immediately; a negative
+// start means the start position is not known up front.
+func (e *endOffsets) set(topic string, partition int32, start, end int64) {
+	if e.ends[topic] == nil {
+		e.ends[topic] = make(map[int32]int64)
+	}
+	if end <= 0 || (start >= 0 && start >= end) {
+		return
+	}
+	e.ends[topic][partition] = end
+	e.remaining++
+}
+
+// inRange reports whether r lies before the end offset of its partition.
+// Records of exhausted or untracked partitions are never in range.
+func (e *endOffsets) inRange(r *kgo.Record) bool {
+	end, ok := e.ends[r.Topic][r.Partition]
+	return ok && r.Offset < end
+}
+
+// reach records that a partition has been fetched up to next, the offset
+// after its last fetched record, while the log ends at logEnd. The partition
+// is exhausted once next passes its end offset or the log itself, so offsets
+// that are never delivered, such as transaction markers or compacted records,
+// do not keep it open. reach reports whether the partition has just become
+// exhausted.
+func (e *endOffsets) reach(topic string, partition int32, next, logEnd int64) bool {
+	end, ok := e.ends[topic][partition]
+	if !ok || (next < end && next < logEnd) {
+		return false
+	}
+	delete(e.ends[topic], partition)
+	e.remaining--
+	return true
+}
+
+// finished reports whether every partition has reached its end offset.
+func (e *endOffsets) finished() bool { return e.remaining == 0 }
@@ -0,0 +1,225 @@
+package kafka
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	now := time.Date(2024, 1, 15, 14, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{"unix millis", "1705327320000", 1705327320000, false},
+		{"RFC3339", "2024-01-15T14:02:00Z", time.Date(2024, 1, 15, 14, 2, 0, 0, time.UTC).UnixMilli(), false},
+		{"RFC3339 with zone", "2024-01-15T23:02:00+09:00", time.Date(2024, 1, 15, 14, 2, 0, 0, time.UTC).UnixMilli(), false},
+		{"relative minutes", "-15m", now.Add(-15 * time.Minute).UnixMilli(), false},
+		{"relative hours", "-1h30m", now.Add(-90 * time.Minute).UnixMilli(), false},
+		{"empty", "", 0, true},
+		{"garbage", "yesterday", 0, true},
+		{"duration without sign", "15m", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTimestamp(tt.input, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTimestamp(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseTimestamp(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseStartOffset(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		input      string
+		wantStored bool
+		wantErr    bool
+	}{
+		{"end", "end", false, false},
+		{"default", "", false, false},
+		{"beginning", "beginning", false, false},
+		{"stored", "stored", true, false},
+		{"timestamp millis", "timestamp:1705327320000", false, false},
+		{"timestamp relative", "timestamp:-15m", false, false},
+		{"timestamp invalid", "timestamp:soon", false, true},
+		{"absolute", "12345", false, false},
+		{"negative absolute", "-5", false, true},
+		{"unknown", "middle", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseStartOffset(tt.input, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseStartOffset(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got.stored != tt.wantStored {
+				t.Errorf("parseStartOffset(%q) stored = %v, want %v", tt.input, got.stored, tt.wantStored)
+			}
+		})
+	}
+}
+
+func TestParsePartitionOffsets(t *testing.T) {
+	now := time.Now()
+
+	got, err := parsePartitionOffsets("3:12345, 5:900,7:beginning", now)
+	if err != nil {
+		t.Fatalf("parsePartitionOffsets failed: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d partitions, want 3", len(got))
+	}
+	if s := got[3]; !s.exact || s.at != 12345 {
+		t.Errorf("partition 3 = %+v, want exact offset 12345", s)
+	}
+	if s := got[5]; !s.exact || s.at != 900 {
+		t.Errorf("partition 5 = %+v, want exact offset 900", s)
+	}
+	if s := got[7]; s.exact || s.listTimestamp != listOffsetsEarliest {
+		t.Errorf("partition 7 = %+v, want beginning", s)
+	}
+
+	for _, spec := range []string{"end", "beginning", "12345", "timestamp:1705327320000", "timestamp:-15m"} {
+		got, err := parsePartitionOffsets(spec, now)
+		if err != nil || got != nil {
+			t.Errorf("parsePartitionOffsets(%q) = %v, %v, want nil, nil", spec, got, err)
+		}
+	}
+
+	for _, spec := range []string{"3:12345,5", "3:1,3:2", "3:abc", "3:1,x:2"} {
+		if _, err := parsePartitionOffsets(spec, now); err == nil {
+			t.Errorf("parsePartitionOffsets(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestParseEndBound(t *testing.T) {
+	now := time.Date(2024, 1, 15, 14, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		input   string
+		want    endBound
+		wantErr bool
+	}{
+		{"none", "", endBound{kind: untilNone}, false},
+		{"now", "now", endBound{kind: untilNow}, false},
+		{"offset", "offset:500", endBound{kind: untilOffset, value: 500}, false},
+		{"timestamp", "timestamp:-30m", endBound{kind: untilTimestamp, value: now.Add(-30 * time.Minute).UnixMilli()}, false},
+		{"negative offset", "offset:-1", endBound{}, true},
+		{"bad offset", "offset:abc", endBound{}, true},
+		{"bad timestamp", "timestamp:", endBound{}, true},
+		{"unknown", "forever", endBound{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseEndBound(tt.input, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseEndBound(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseEndBound(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEndOffsets(t *testing.T) {
+	e := newEndOffsets()
+	e.set("events", 0, 10, 12) // two records left
+	e.set("events", 1, 5, 5)   // already at the end
+	e.set("events", 2, -1, 0)  // empty partition
+
+	if e.finished() {
+		t.Fatal("finished() = true before consuming partition 0")
+	}
+
+	if !e.inRange(&kgo.Record{Topic: "events", Partition: 0, Offset: 10}) {
+		t.Error("offset 10 reported out of range")
+	}
+	if e.reach("events", 0, 11, 20) {
+		t.Error("partition exhausted after offset 10 of 12")
+	}
+	if !e.inRange(&kgo.Record{Topic: "events", Partition: 0, Offset: 11}) {
+		t.Error("offset 11 reported out of range")
+	}
+	if !e.reach("events", 0, 12, 20) {
+		t.Error("partition not exhausted after reaching its end offset")
+	}
+	if !e.finished() {
+		t.Error("finished() = false after reaching every end offset")
+	}
+	if e.inRange(&kgo.Record{Topic: "events", Partition: 0, Offset: 12}) {
+		t.Error("offset 12 reported in range after partition was exhausted")
+	}
+}
+
+func TestEndOffsetsPastLog(t *testing.T) {
+	// --until offset:100 on partitions whose logs end at 40 and 60.
+	ends := offsetEnds(100, map[int32]int64{0: 40, 1: 60, 2: 150})
+	if want := map[int32]int64{0: 40, 1: 60, 2: 100}; !reflect.DeepEqual(ends, want) {
+		t.Fatalf("offsetEnds = %v, want %v", ends, want)
+	}
+
+	e := newEndOffsets()
+	e.set("events", 0, 40, ends[0]) // starts at the log end, as with -o end
+	e.set("events", 1, 10, ends[1]) // records left to fetch
+	if e.reach("events", 1, 50, 60) {
+		t.Error("partition 1 exhausted before reaching the log end")
+	}
+	if !e.reach("events", 1, 60, 60) {
+		t.Error("partition 1 not exhausted at the log end")
+	}
+	if !e.finished() {
+		t.Error("finished() = false, want the partition starting at the log end to be done")
+	}
+}
+
+func TestEndOffsetsSkippedEnd(t *testing.T) {
+	tests := []struct {
+		name   string
+		next   int64
+		logEnd int64
+	}{
+		// A transaction marker at 19 is delivered as a control record.
+		{"marker at end-1", 20, 20},
+		// Compaction removed 18 and 19, and the next record is past the end.
+		{"compacted end-1", 26, 30},
+		// The end offset lies beyond the log.
+		{"end past log", 15, 15},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEndOffsets()
+			e.set("events", 0, 10, 20)
+			if !e.reach("events", 0, tt.next, tt.logEnd) {
+				t.Errorf("reach(%d, log end %d) = false, want true", tt.next, tt.logEnd)
+			}
+			if !e.finished() {
+				t.Error("finished() = false after the partition was exhausted")
+			}
+		})
+	}
+
+	e := newEndOffsets()
+	e.set("events", 0, 10, 20)
+	if e.reach("events", 0, 18, 25) {
+		t.Error("reach(18, log end 25) = true with offsets 18 and 19 still to come")
+	}
+}
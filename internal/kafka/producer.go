@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/HurSungYun/buf-kcat/internal/decoder"
+	"github.com/HurSungYun/buf-kcat/internal/registry"
 	"github.com/twmb/franz-go/pkg/kgo"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
@@ -17,12 +18,32 @@ type ProducerConfig struct {
 	Brokers     []string
 	Topic       string
 	ProtoPath   string
+	ImportPaths []string
+	CacheDir    string
+	Strict      bool
 	MessageType string
 	Key         string
 	Partition   int32
 	Verbose     bool
+	// KeyFormat is one of the KeyFormat constants that Key is written in;
+	// KeyType instead encodes Key, given as JSON, as a message type.
+	KeyFormat string
+	KeyType   string
+	// WireFormat is WireFormatBare or WireFormatConfluent. The Confluent
+	// format needs SchemaID, SchemaRegistry, or both; without SchemaID the
+	// latest schema registered under Subject is used.
+	WireFormat     string
+	SchemaID       uint32
+	SchemaRegistry string
+	Subject        string
 }
 
+// Wire formats for ProducerConfig.WireFormat.
+const (
+	WireFormatBare      = "bare"
+	WireFormatConfluent = "confluent"
+)
+
 // Producer wraps Kafka client and protobuf encoder for producing messages.
 type Producer struct {
 	client      *kgo.Client
@@ -30,8 +51,12 @@ type Producer struct {
 	messageType string
 	topic       string
 	partition   int32
-	key         string
+	key         []byte
 	verbose     bool
+
+	// frame is the Confluent framing prepended to every value, or nil to
+	// produce bare protobuf.
+	frame *decoder.ConfluentFrame
 }
 
 // NewProducer initializes a Producer.
@@ -43,11 +68,32 @@ func NewProducer(cfg ProducerConfig) (*Producer, error) {
 		return nil, fmt.Errorf("message type is required")
 	}
 
-	dec, err := decoder.NewDecoder(cfg.ProtoPath, cfg.MessageType)
+	dec, err := decoder.NewDecoderWithOptions(cfg.ProtoPath, cfg.MessageType, decoder.Options{
+		ImportPaths: cfg.ImportPaths,
+		CacheDir:    cfg.CacheDir,
+		Strict:      cfg.Strict,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize decoder: %w", err)
 	}
 
+	frame, err := confluentFrame(cfg, dec)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := newKeyCodec(cfg.KeyFormat, cfg.KeyType, dec)
+	if err != nil {
+		return nil, err
+	}
+	var key []byte
+	if cfg.Key != "" {
+		key, err = keys.encode(cfg.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode key: %w", err)
+		}
+	}
+
 	opts := []kgo.Opt{
 		kgo.SeedBrokers(cfg.Brokers...),
 		kgo.DefaultProduceTopic(cfg.Topic),
@@ -67,11 +113,79 @@ func NewProducer(cfg ProducerConfig) (*Producer, error) {
 		messageType: cfg.MessageType,
 		topic:       cfg.Topic,
 		partition:   cfg.Partition,
-		key:         cfg.Key,
+		key:         key,
 		verbose:     cfg.Verbose,
+		frame:       frame,
 	}, nil
 }
 
+// confluentFrame resolves the schema ID and message indexes of the Confluent
+// framing for cfg, or returns nil for bare protobuf. With a registry the
+// indexes come from the registered schema, so they match what Confluent
+// deserializers expect even if the local files are laid out differently.
+func confluentFrame(cfg ProducerConfig, dec *decoder.Decoder) (*decoder.ConfluentFrame, error) {
+	switch cfg.WireFormat {
+	case "", WireFormatBare:
+		if cfg.SchemaID != 0 || cfg.SchemaRegistry != "" {
+			return nil, fmt.Errorf("--schema-id and --schema-registry require --wire-format confluent")
+		}
+		return nil, nil
+	case WireFormatConfluent:
+	default:
+		return nil, fmt.Errorf("invalid wire format %q: expected bare or confluent", cfg.WireFormat)
+	}
+
+	if cfg.SchemaRegistry == "" {
+		if cfg.SchemaID == 0 {
+			return nil, fmt.Errorf("--wire-format confluent requires --schema-id or --schema-registry")
+		}
+		msgType, ok := dec.GetMessageTypes()[cfg.MessageType]
+		if !ok {
+			return nil, fmt.Errorf("message type not found: %s", cfg.MessageType)
+		}
+		indexes, err := decoder.ConfluentIndexes(msgType.Descriptor().ParentFile(), cfg.MessageType)
+		if err != nil {
+			return nil, err
+		}
+		return &decoder.ConfluentFrame{SchemaID: cfg.SchemaID, Indexes: indexes}, nil
+	}
+
+	client, err := registry.NewClient(cfg.SchemaRegistry)
+	if err != nil {
+		return nil, err
+	}
+	id := cfg.SchemaID
+	if id == 0 {
+		subject := cfg.Subject
+		if subject == "" {
+			// Confluent's default TopicNameStrategy.
+			subject = cfg.Topic + "-value"
+		}
+		id, err = client.LatestSchemaID(subject)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up schema for subject %s: %w", subject, err)
+		}
+	}
+	fd, err := client.Schema(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch schema %d: %w", id, err)
+	}
+	indexes, err := decoder.ConfluentIndexes(fd, cfg.MessageType)
+	if err != nil {
+		return nil, fmt.Errorf("schema %d: %w", id, err)
+	}
+	return &decoder.ConfluentFrame{SchemaID: id, Indexes: indexes}, nil
+}
+
+// SchemaID returns the schema ID written with every record, or 0 when
+// producing bare protobuf.
+func (p *Producer) SchemaID() uint32 {
+	if p.frame == nil {
+		return 0
+	}
+	return p.frame.SchemaID
+}
+
 // Close closes the underlying Kafka client.
 func (p *Producer) Close() { p.client.Close() }
 
@@ -91,13 +205,19 @@ func (p *Producer) ProduceJSON(ctx context.Context, jsonLine string) (*kgo.Recor
 		return nil, fmt.Errorf("failed to encode message: %w", err)
 	}
 
+	if p.frame != nil {
+		frame := *p.frame
+		frame.Payload = protoBytes
+		protoBytes = frame.Marshal()
+	}
+
 	record := &kgo.Record{
 		Topic:     p.topic,
 		Value:     protoBytes,
 		Partition: p.partition,
 	}
-	if p.key != "" {
-		record.Key = []byte(p.key)
+	if p.key != nil {
+		record.Key = p.key
 	}
 
 	result := p.client.ProduceSync(ctx, record)
@@ -109,6 +229,9 @@ func (p *Producer) ProduceJSON(ctx context.Context, jsonLine string) (*kgo.Recor
 }
 
 // encodeMessage converts JSON to protobuf bytes using the configured decoder.
+// The contents of Any fields and extensions are resolved among its types.
+// The encoding is deterministic, so that the same message used as a key
+// always maps to the same bytes, partition and compacted entry.
 func encodeMessage(dec *decoder.Decoder, msgTypeName string, jsonData []byte) ([]byte, error) {
 	msgTypes := dec.GetMessageTypes()
 	msgType, ok := msgTypes[msgTypeName]
@@ -120,12 +243,13 @@ func encodeMessage(dec *decoder.Decoder, msgTypeName string, jsonData []byte) ([
 	unmarshaler := protojson.UnmarshalOptions{
 		DiscardUnknown: false,
 		AllowPartial:   false,
+		Resolver:       dec.Types(),
 	}
 	if err := unmarshaler.Unmarshal(jsonData, msg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal JSON to proto: %w", err)
 	}
 
-	protoBytes, err := proto.Marshal(msg)
+	protoBytes, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal proto: %w", err)
 	}
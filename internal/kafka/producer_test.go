@@ -0,0 +1,124 @@
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/HurSungYun/buf-kcat/internal/decoder"
+)
+
+func TestConfluentFrame(t *testing.T) {
+	dec, err := decoder.NewDecoder(testDescriptorSet, "events.OrderEvent")
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+
+	// The registry's schema puts OrderEvent first, unlike events.proto.
+	const schema = `syntax = "proto3"; package events; message OrderEvent { string order_id = 1; } message UserEvent { string user_id = 1; }`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/subjects/orders-value/versions/latest":
+			fmt.Fprintf(w, `{"id":12,"schema":%q}`, schema)
+		case "/schemas/ids/12", "/schemas/ids/13":
+			fmt.Fprintf(w, `{"schema":%q}`, schema)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	base := func() ProducerConfig {
+		return ProducerConfig{
+			Topic:       "orders",
+			MessageType: "events.OrderEvent",
+			WireFormat:  WireFormatConfluent,
+		}
+	}
+	tests := []struct {
+		name        string
+		modify      func(*ProducerConfig)
+		wantID      uint32
+		wantIndexes string
+		wantErr     string
+	}{
+		{"bare", func(c *ProducerConfig) { c.WireFormat = "" }, 0, "", ""},
+		{"local schema ID", func(c *ProducerConfig) { c.SchemaID = 42 }, 42, "[2]", ""},
+		{"registry lookup", func(c *ProducerConfig) { c.SchemaRegistry = server.URL }, 12, "[0]", ""},
+		{"registry with schema ID", func(c *ProducerConfig) { c.SchemaRegistry = server.URL; c.SchemaID = 13 }, 13, "[0]", ""},
+		{"missing schema ID", func(*ProducerConfig) {}, 0, "", "requires --schema-id or --schema-registry"},
+		{"schema ID without confluent", func(c *ProducerConfig) { c.WireFormat = WireFormatBare; c.SchemaID = 1 }, 0, "", "require --wire-format confluent"},
+		{"unknown wire format", func(c *ProducerConfig) { c.WireFormat = "avro" }, 0, "", "invalid wire format"},
+		{"unknown subject", func(c *ProducerConfig) { c.SchemaRegistry = server.URL; c.Subject = "missing" }, 0, "", "subject missing"},
+		{"type not in schema", func(c *ProducerConfig) { c.SchemaRegistry = server.URL; c.MessageType = "events.SystemEvent" }, 0, "", "not declared"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base()
+			tt.modify(&cfg)
+			frame, err := confluentFrame(cfg, dec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("confluentFrame error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("confluentFrame failed: %v", err)
+			}
+			if tt.wantID == 0 {
+				if frame != nil {
+					t.Errorf("confluentFrame = %+v, want nil for bare protobuf", frame)
+				}
+				return
+			}
+			if frame.SchemaID != tt.wantID || fmt.Sprint(frame.Indexes) != tt.wantIndexes {
+				t.Errorf("confluentFrame = id %d indexes %v, want id %d indexes %s", frame.SchemaID, frame.Indexes, tt.wantID, tt.wantIndexes)
+			}
+		})
+	}
+}
+
+func TestEncodeMessageAny(t *testing.T) {
+	dec, err := decoder.NewDecoder(testDescriptorSet, "events.CustomData")
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+
+	input := `{
+		"payload": {
+			"@type": "type.googleapis.com/events.UserEvent",
+			"user_id": "user-1",
+			"timestamp": "2024-01-02T03:04:05Z"
+		},
+		"data_type": "user"
+	}`
+	data, err := encodeMessage(dec, "events.CustomData", []byte(input))
+	if err != nil {
+		t.Fatalf("encodeMessage failed: %v", err)
+	}
+
+	jsonData, _, err := dec.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	var got, want map[string]any
+	if err := json.Unmarshal(jsonData, &got); err != nil {
+		t.Fatalf("invalid JSON %s: %v", jsonData, err)
+	}
+	if err := json.Unmarshal([]byte(input), &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Any did not round-trip:\ngot  %v\nwant %v", got, want)
+	}
+
+	_, err = encodeMessage(dec, "events.CustomData", []byte(`{"payload": {"@type": "type.googleapis.com/events.Missing"}}`))
+	if err == nil {
+		t.Error("encodeMessage accepted an Any of an unknown type")
+	}
+}
@@ -0,0 +1,148 @@
+package registry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const orderSchema = `syntax = "proto3";
+package shop;
+
+import "common/money.proto";
+import "google/protobuf/timestamp.proto";
+
+message Order {
+  string id = 1;
+  common.Money total = 2;
+  google.protobuf.Timestamp created_at = 3;
+
+  message Line {
+    string sku = 1;
+  }
+}
+`
+
+const moneySchema = `syntax = "proto3";
+package common;
+
+message Money {
+  string currency = 1;
+  int64 units = 2;
+}
+`
+
+func newStubRegistry(t *testing.T, requests *int32) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		if user, pass, ok := r.BasicAuth(); !ok || user != "reader" || pass != "secret" {
+			http.Error(w, `{"error_code":401,"message":"Unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+		switch r.URL.EscapedPath() {
+		case "/schemas/ids/7":
+			w.Write([]byte(`{"schemaType":"PROTOBUF","schema":` + quote(orderSchema) +
+				`,"references":[{"name":"common/money.proto","subject":"common/money.proto","version":3}]}`))
+		case "/subjects/orders-value/versions/latest":
+			w.Write([]byte(`{"subject":"orders-value","version":4,"id":7,"schemaType":"PROTOBUF","schema":` + quote(orderSchema) + `}`))
+		case "/subjects/common%2Fmoney.proto/versions/3":
+			w.Write([]byte(`{"subject":"common/money.proto","version":3,"id":2,"schemaType":"PROTOBUF","schema":` + quote(moneySchema) + `}`))
+		case "/schemas/ids/8":
+			w.Write([]byte(`{"schemaType":"AVRO","schema":"{\"type\":\"string\"}"}`))
+		default:
+			http.Error(w, `{"error_code":40403,"message":"Schema not found"}`, http.StatusNotFound)
+		}
+	}))
+}
+
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + strings.ReplaceAll(s, "\n", `\n`) + `"`
+}
+
+func TestClientSchema(t *testing.T) {
+	var requests int32
+	server := newStubRegistry(t, &requests)
+	defer server.Close()
+
+	client, err := NewClient(strings.Replace(server.URL, "http://", "http://reader:secret@", 1))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if strings.Contains(client.String(), "secret") {
+		t.Errorf("String() leaks the password: %s", client)
+	}
+
+	fd, err := client.Schema(7)
+	if err != nil {
+		t.Fatalf("Schema failed: %v", err)
+	}
+	order := fd.Messages().ByName("Order")
+	if order == nil {
+		t.Fatalf("schema has no Order message")
+	}
+	if got := order.Fields().ByName("total").Message().FullName(); got != "common.Money" {
+		t.Errorf("total field type = %s, want common.Money", got)
+	}
+	if got := order.Messages().Get(0).FullName(); got != "shop.Order.Line" {
+		t.Errorf("nested message = %s, want shop.Order.Line", got)
+	}
+
+	id, err := client.LatestSchemaID("orders-value")
+	if err != nil {
+		t.Fatalf("LatestSchemaID failed: %v", err)
+	}
+	if id != 7 {
+		t.Errorf("LatestSchemaID = %d, want 7", id)
+	}
+
+	before := atomic.LoadInt32(&requests)
+	if _, err := client.Schema(7); err != nil {
+		t.Fatalf("cached Schema failed: %v", err)
+	}
+	if after := atomic.LoadInt32(&requests); after != before {
+		t.Errorf("cached lookup made %d requests", after-before)
+	}
+}
+
+func TestClientSchemaErrors(t *testing.T) {
+	var requests int32
+	server := newStubRegistry(t, &requests)
+	defer server.Close()
+
+	authed, err := NewClient(strings.Replace(server.URL, "http://", "http://reader:secret@", 1))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	anonymous, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		client *Client
+		id     uint32
+		want   string
+	}{
+		{"missing schema", authed, 99, "404"},
+		{"not protobuf", authed, 8, "AVRO"},
+		{"unauthorized", anonymous, 7, "401"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.client.Schema(tt.id)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Schema(%d) error = %v, want it to contain %q", tt.id, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewClient("localhost:8081"); err == nil {
+		t.Error("NewClient accepted a URL without a scheme")
+	}
+}